@@ -3,12 +3,19 @@ package main
 import (
 	"encoding/json"
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"strings"
+	"os"
 	"strconv"
+	"text/tabwriter"
 
+	"github.com/9elements/goswid/pkg/cbfs"
+	"github.com/9elements/goswid/pkg/elfsection"
+	"github.com/9elements/goswid/pkg/fmap"
+	"github.com/9elements/goswid/pkg/pesection"
 	"github.com/9elements/goswid/pkg/uswid"
 	"github.com/CodingVoid/swid"
 	"github.com/google/uuid"
@@ -17,48 +24,108 @@ import (
 type FileType int
 
 var cli struct {
-	Debug         bool               `help:"Enable debug mode"`
+	Debug bool `help:"Enable debug mode"`
 
 	GenerateTagID  generateTagIDCmd  `cmd help:"generates a 16 byte type-5 SHA1 RFC 4122 UUID (possible use for tag-id)"`
 	Print          printCmd          `cmd help:"print swid tag to stdout (in json format)"`
 	Convert        convertCmd        `cmd help:"convert between SWID/CoSWID and different file formats (json, xml, cbor, uswid)"`
 	AddPayloadFile addPayloadFileCmd `cmd help:"add payload file into an existing CoSWID tag"`
 	AddLicense     addLicenseCmd     `cmd help:"add license into an existing CoSWID tag"`
+	Inject         injectCmd         `cmd help:"write uSWID data into a reserved region of an existing binary image"`
+	Strip          stripCmd          `cmd help:"remove all uSWID data from a binary image"`
+	CbfsList       cbfsListCmd       `cmd help:"list the CBFS files of a coreboot image"`
+	Embed          embedCmd          `cmd help:"add or replace the uSWID section of an ELF binary, or append it to a PE/COFF image"`
+	Validate       validateCmd       `cmd help:"check CoSWID tags against the rules of RFC 9393 and SWID XML against ISO/IEC 19770-2:2015, exits non-zero on violations"`
+}
+
+type validateCmd struct {
+	InputTags   []string `flag required short:"i" name:"input" help:"Paths to imput files (comma seperated), each file is validated on its own" type:"existingfile"`
+	Region      string   `flag optional name:"region" help:"only search this FMAP region (e.g. COREBOOT or FW_MAIN_A) of binary input images for uSWID data"`
+	Section     string   `flag optional name:"section" help:"name of the ELF or PE section holding the uSWID data of ELF and PE/COFF input files" default:".sbom"`
+	CBFSFile    string   `flag optional name:"cbfs-file" help:"name of the CBFS file holding the uSWID data of coreboot input images" default:"sbom"`
+	InputFormat string   `flag optional name:"input-format" help:"format of all input files, e.g. json, xml, cbor, uswid, pc, image, gzip, xz, spdx-json, spdx, cyclonedx-json or cyclonedx-xml. if this option is ommited, the format is detected from the file content"`
+}
+
+type embedCmd struct {
+	Binary        string   `arg required help:"ELF binary or PE/COFF image (e.g. EFI application) to write the uSWID data into" type:"existingfile"`
+	InputTags     []string `flag required short:"i" name:"input" help:"Paths to imput files (comma seperated), which are merged into the embedded uSWID blob" type:"existingfile"`
+	OutputFile    string   `flag optional short:"o" name:"output" help:"output binary, the input binary is modified in place if this option is ommited" type:"path"`
+	Section       string   `flag optional name:"section" help:"name of the section to write the uSWID data to, at most 8 characters for PE/COFF images" default:".sbom"`
+	Compression   string   `flag optional name:"compression" help:"compression of the CoSWID payload, either none, zlib or lzma" default:"none" enum:"none,zlib,lzma"`
+	HeaderVersion uint8    `flag optional name:"header-version" help:"uSWID header version to write (2 or 3). defaults to 2, or 3 for lzma compression"`
+}
+
+type cbfsListCmd struct {
+	Image string `arg required help:"coreboot image" type:"existingfile"`
+}
+
+type stripCmd struct {
+	Image      string `arg required help:"binary image (e.g. coreboot.rom) to remove the uSWID data from" type:"existingfile"`
+	OutputFile string `flag optional short:"o" name:"output" help:"output image, the input image is modified in place if this option is ommited" type:"path"`
+	Mode       string `flag optional name:"mode" help:"erase overwrites the whole uSWID blob, truncate keeps the header but drops the CoSWID payload" default:"erase" enum:"erase,truncate"`
+	Pad        string `flag optional name:"pad" help:"byte used to overwrite the uSWID data" default:"0xff"`
+}
+
+type injectCmd struct {
+	Image         string   `arg required help:"binary image (e.g. coreboot.rom) to write the uSWID data into" type:"existingfile"`
+	InputTags     []string `flag required short:"i" name:"input" help:"Paths to imput files (comma seperated), which are merged into the injected uSWID blob" type:"existingfile"`
+	OutputFile    string   `flag optional short:"o" name:"output" help:"output image, the input image is modified in place if this option is ommited" type:"path"`
+	Offset        string   `flag optional name:"offset" help:"offset of the region to write the uSWID data to (requires --size)"`
+	Size          string   `flag optional name:"size" help:"size of the region at --offset, or the size the region of the replaced blob is grown to"`
+	Placeholder   string   `flag optional name:"placeholder" help:"hex encoded pattern, the region is the first run of repetitions of this pattern in the image"`
+	Index         int      `flag optional name:"index" help:"replace the n-th uSWID blob in the image. this is used if neither --offset nor --placeholder is given" default:"0"`
+	Pad           string   `flag optional name:"pad" help:"byte used to fill the remainder of the region" default:"0xff"`
+	Compression   string   `flag optional name:"compression" help:"compression of the CoSWID payload, either none, zlib or lzma" default:"none" enum:"none,zlib,lzma"`
+	HeaderVersion uint8    `flag optional name:"header-version" help:"uSWID header version to write (2 or 3). defaults to 2, or 3 for lzma compression"`
 }
 
 type addLicenseCmd struct {
-	LicenseHref	[]string `arg required help:"link to license (e.g. SPDX license link)"`
-	InputFile   string `flag required short:"i" name:"input-file" help:"Path to imput files." type:"existingfile"`
-	OutputFile	string `flag required short:"o" name:"output-file" help:"output file, either .json .xml .cbor or .uswid file" type:"path"`
+	LicenseHref []string `arg required help:"link to license (e.g. SPDX license link)"`
+	InputFile   string   `flag required short:"i" name:"input-file" help:"Path to imput files." type:"existingfile"`
+	OutputFile  string   `flag required short:"o" name:"output-file" help:"output file, either .json .xml .cbor or .uswid file" type:"path"`
 }
 
 type addPayloadFileCmd struct {
-	PayloadFileName	string `flag required name:"name" help:"filename that should be added to the payload portion of the CoSWID tag"`
+	PayloadFileName    string `flag required name:"name" help:"filename that should be added to the payload portion of the CoSWID tag"`
 	PayloadFileVersion string `flag optional name:"version" help:"version of the payload file"`
-	InputFile   string `flag required short:"i" name:"input-file" help:"Path to imput files." type:"existingfile"`
-	OutputFile	string `flag required short:"o" name:"output-file" help:"output file, either .json .xml .cbor or .uswid file" type:"path"`
+	InputFile          string `flag required short:"i" name:"input-file" help:"Path to imput files." type:"existingfile"`
+	OutputFile         string `flag required short:"o" name:"output-file" help:"output file, either .json .xml .cbor or .uswid file" type:"path"`
 }
 
 type convertCmd struct {
-	ParentTag    string   `flag optional name:"parent" help:"It is assumed that for all supplied files, the first tag of each file is a parent tag. goswid will automatically add a link (with dependency link type) between the first given uSWID/CoSWID Tag and all other parent tags" type="existingfile"`
-	InputTags   []string  `flag optional short:"i" name:"input" help:"Paths to imput files (comma seperated)" type:"existingfile"`
-	RequiredTags []string `flag optional name:"requires" help:"Paths to imput files (comma seperated), which should have a 'required' link to ParentTag" type:"existingfile"`
-	CompilerTags []string `flag optional name:"compiler" help:"Paths to imput files (comma seperated), which should have a 'Compiler' link to ParentTag" type:"existingfile"`
-	OutputFile	 string   `flag required short:"o" name:"output" help:"output file, either .json .xml .cbor .uswid file or a dash '-' for stdout" type:"path"`
-	OutputFormat string   `flag optional name:"output-format" help:"file format of output file. either json, xml, cbor or uswid. if this option is ommited, format will be guessed according to the OutputFile extension"`
-	ZlibCompress bool     `flag optional short:"z" name:"zlib-compress" help:"zlib (RFC 1950) compress output, only possible with .uswid file as output" type:"path"`
+	ParentTag     string   `flag optional name:"parent" help:"It is assumed that for all supplied files, the first tag of each file is a parent tag. goswid will automatically add a link (with dependency link type) between the first given uSWID/CoSWID Tag and all other parent tags" type:"existingfile"`
+	InputTags     []string `flag optional short:"i" name:"input" help:"Paths to imput files (comma seperated)" type:"existingfile"`
+	RequiredTags  []string `flag optional name:"requires" help:"Paths to imput files (comma seperated), which should have a 'required' link to ParentTag" type:"existingfile"`
+	CompilerTags  []string `flag optional name:"compiler" help:"Paths to imput files (comma seperated), which should have a 'Compiler' link to ParentTag" type:"existingfile"`
+	OutputFile    string   `flag required short:"o" name:"output" help:"output file, e.g. a .json .xml .cbor .uswid .spdx.json .spdx .cdx.json .cdx.xml .plantuml .mmd .dot .graphml or .html file, or a dash '-' for stdout" type:"path"`
+	OutputFormat  string   `flag optional name:"output-format" help:"file format of output file, e.g. json, xml, cbor, uswid, spdx-json, spdx (tag-value), cyclonedx-json, cyclonedx-xml, plantuml, mermaid, dot, graphml or html (report). if this option is ommited, format will be guessed according to the OutputFile extension"`
+	ZlibCompress  bool     `flag optional short:"z" name:"zlib-compress" help:"zlib (RFC 1950) compress output, same as --compression=zlib"`
+	Compression   string   `flag optional name:"compression" help:"compression of the CoSWID payload, either none, zlib or lzma. only possible with .cbor or .uswid file as output" default:"none" enum:"none,zlib,lzma"`
+	ListOffsets   bool     `flag optional name:"list-offsets" help:"print the offsets of all uSWID blobs found in the input files"`
+	HeaderVersion uint8    `flag optional name:"header-version" help:"uSWID header version to write (2 or 3), only used with .uswid file as output. defaults to 2, or 3 for lzma compression"`
+	Region        string   `flag optional name:"region" help:"only search this FMAP region (e.g. COREBOOT or FW_MAIN_A) of binary input images for uSWID data"`
+	Section       string   `flag optional name:"section" help:"name of the ELF or PE section holding the uSWID data of ELF and PE/COFF input files" default:".sbom"`
+	CBFSFile      string   `flag optional name:"cbfs-file" help:"name of the CBFS file holding the uSWID data of coreboot input images" default:"sbom"`
+	InputFormat   string   `flag optional name:"input-format" help:"format of all input files, e.g. json, xml, cbor, uswid, pc, image, gzip, xz, spdx-json, spdx, cyclonedx-json or cyclonedx-xml. if this option is ommited, the format is detected from the file content"`
+	Validate      bool     `flag optional name:"validate" help:"refuse to write tags violating RFC 9393, or XML output violating ISO/IEC 19770-2:2015"`
+	Depth         int      `flag optional name:"depth" help:"only draw links up to this many steps from the root tags in mermaid, dot and graphml output, 0 draws all"`
 }
 
 type generateTagIDCmd struct {
-	UuidgenName string   `flag required short:"n" name:"name" help:"string to use for uuid generation (e.g. software name)"`
+	UuidgenName string `flag required short:"n" name:"name" help:"string to use for uuid generation (e.g. software name)"`
 }
 
 type printCmd struct {
-	ParentTag    string   `flag optional name:"parent" help:"It is assumed that for all supplied files, the first tag of each file is a parent tag. goswid will automatically add a link (with dependency link type) between the first given uSWID/CoSWID Tag and all other parent tags" type="existingfile"`
-	InputTags   []string  `flag optional short:"i" name:"input" help:"Paths to imput files (comma seperated)" type:"existingfile"`
+	ParentTag    string   `flag optional name:"parent" help:"It is assumed that for all supplied files, the first tag of each file is a parent tag. goswid will automatically add a link (with dependency link type) between the first given uSWID/CoSWID Tag and all other parent tags" type:"existingfile"`
+	InputTags    []string `flag optional short:"i" name:"input" help:"Paths to imput files (comma seperated)" type:"existingfile"`
 	RequiredTags []string `flag optional name:"requires" help:"Paths to imput files (comma seperated), which should have a 'required' link to ParentTag" type:"existingfile"`
 	CompilerTags []string `flag optional name:"compiler" help:"Paths to imput files (comma seperated), which should have a 'Compiler' link to ParentTag" type:"existingfile"`
 	OutputFormat string   `flag optional name:"output-format" help:"format in which to pretty print the output. currently only json"`
+	Region       string   `flag optional name:"region" help:"only search this FMAP region (e.g. COREBOOT or FW_MAIN_A) of binary input images for uSWID data"`
+	Section      string   `flag optional name:"section" help:"name of the ELF or PE section holding the uSWID data of ELF and PE/COFF input files" default:".sbom"`
+	CBFSFile     string   `flag optional name:"cbfs-file" help:"name of the CBFS file holding the uSWID data of coreboot input images" default:"sbom"`
+	ByRegion     bool     `flag optional name:"by-region" help:"group the tags of a flash image by the FMAP region (e.g. FW_MAIN_A and FW_MAIN_B) holding them"`
+	InputFormat  string   `flag optional name:"input-format" help:"format of all input files, e.g. json, xml, cbor, uswid, pc, image, gzip, xz, spdx-json, spdx, cyclonedx-json or cyclonedx-xml. if this option is ommited, the format is detected from the file content"`
 }
 
 func (a *addLicenseCmd) Run() error {
@@ -80,7 +147,7 @@ func (a *addLicenseCmd) Run() error {
 		utag.Identities[0].AddLink(*link)
 	}
 
-	if err := writeFile(a.OutputFile, "", false, utag); err != nil {
+	if err := writeFile(a.OutputFile, "", uswid.CodecOptions{}, utag, false); err != nil {
 		return err
 	}
 	return nil
@@ -111,7 +178,7 @@ func (a *addPayloadFileCmd) Run() error {
 	}
 	utag.Identities[0].Payload.AddFile(f)
 
-	if err := writeFile(a.OutputFile, "", false, utag); err != nil {
+	if err := writeFile(a.OutputFile, "", uswid.CodecOptions{}, utag, false); err != nil {
 		return err
 	}
 	return nil
@@ -124,11 +191,33 @@ func (c *convertCmd) Run() error {
 	if c.ParentTag == "" && (len(c.CompilerTags) > 0 || len(c.RequiredTags) > 0) {
 		return errors.New("cannot have compiler or required tags without a parent to bind them to")
 	}
-	utag, err := importFiles(c.ParentTag, c.InputTags, c.RequiredTags, c.CompilerTags)
+	opts, err := fileOptions(c.InputFormat, c.Region, c.Section, c.CBFSFile)
+	if err != nil {
+		return err
+	}
+	if c.ListOffsets {
+		files := append([]string{c.ParentTag}, c.InputTags...)
+		files = append(files, c.RequiredTags...)
+		files = append(files, c.CompilerTags...)
+		if err := listOffsets(files, opts); err != nil {
+			return err
+		}
+	}
+	utag, err := importFiles(c.ParentTag, c.InputTags, c.RequiredTags, c.CompilerTags, opts)
+	if err != nil {
+		return err
+	}
+	compression, err := uswid.ParseCompression(c.Compression)
 	if err != nil {
 		return err
 	}
-	if err := writeFile(c.OutputFile, c.OutputFormat, c.ZlibCompress, *utag); err != nil {
+	if c.ZlibCompress {
+		if compression != uswid.CompressionNone && compression != uswid.CompressionZlib {
+			return fmt.Errorf("--zlib-compress conflicts with --compression %s", compression)
+		}
+		compression = uswid.CompressionZlib
+	}
+	if err := writeFile(c.OutputFile, c.OutputFormat, uswid.CodecOptions{Options: []interface{}{uswid.USWIDOptions{HeaderVersion: c.HeaderVersion, Compression: compression}, uswid.GraphOptions{Depth: c.Depth}}}, *utag, c.Validate); err != nil {
 		return err
 	}
 	return nil
@@ -141,7 +230,20 @@ func (p *printCmd) Run() error {
 	if p.ParentTag == "" && (len(p.CompilerTags) > 0 || len(p.RequiredTags) > 0) {
 		return errors.New("cannot have compiler or required tags without a parent to bind them to")
 	}
-	utag, err := importFiles(p.ParentTag, p.InputTags, p.RequiredTags, p.CompilerTags)
+	opts, err := fileOptions(p.InputFormat, p.Region, p.Section, p.CBFSFile)
+	if err != nil {
+		return err
+	}
+	if p.ByRegion {
+		if p.ParentTag != "" || len(p.InputTags) != 1 {
+			return errors.New("--by-region takes a single input image")
+		}
+		if p.OutputFormat != "json" {
+			return fmt.Errorf("cannot pretty print %s format", p.OutputFormat)
+		}
+		return printRegions(p.InputTags[0], opts)
+	}
+	utag, err := importFiles(p.ParentTag, p.InputTags, p.RequiredTags, p.CompilerTags, opts)
 	if err != nil {
 		return err
 	}
@@ -163,119 +265,496 @@ func (p *printCmd) Run() error {
 	return nil
 }
 
-func (g *generateTagIDCmd) Run() {
-	fmt.Println(uuid.NewSHA1(uuid.NameSpaceDNS, []byte(g.UuidgenName)))
-}
+func (i *injectCmd) Run() error {
+	if i.Offset != "" && i.Placeholder != "" {
+		return errors.New("cannot use --offset and --placeholder together")
+	}
+	if i.Offset != "" && i.Size == "" {
+		return errors.New("--offset requires --size")
+	}
+	if i.Placeholder != "" && i.Size != "" {
+		return errors.New("cannot use --placeholder and --size together")
+	}
+	pad, err := strconv.ParseUint(i.Pad, 0, 8)
+	if err != nil {
+		return fmt.Errorf("invalid pad byte: %w", err)
+	}
+	compression, err := uswid.ParseCompression(i.Compression)
+	if err != nil {
+		return err
+	}
+	utag, err := importFiles("", i.InputTags, nil, nil, uswid.CodecOptions{})
+	if err != nil {
+		return err
+	}
+	blob, err := utag.ToUSWIDOptions(uswid.USWIDOptions{HeaderVersion: i.HeaderVersion, Compression: compression})
+	if err != nil {
+		return err
+	}
+	image, err := ioutil.ReadFile(i.Image)
+	if err != nil {
+		return err
+	}
 
-func recursivePlantUML(stringBuilder *strings.Builder, beenThere []bool, uid *uswid.UswidSoftwareIdentity, i int) {
-	beenThere[i] = true
-	currentId := uid.Identities[i]
-	stringBuilder.WriteString(`object "`)
-	stringBuilder.WriteString(currentId.SoftwareName)
-	stringBuilder.WriteString(`" as `)
-	stringBuilder.WriteString(strconv.Itoa(i))
-	stringBuilder.WriteRune('\n')
-	if currentId.Links == nil {
-		return
-	}
-	for _, link := range *currentId.Links {
-		//TODO comparing the string values is quite slow, but the values are probably saved as strings inside link.Rel
-		if link.Rel.String() == swid.NewRel(swid.RelRequires).String() || link.Rel.String() == swid.NewRel(swid.RelCompiler).String() {
-			for index, id := range uid.Identities {
-				// speed it up a bit by ignoring tag's where we have already been
-				if beenThere[index] {
-					continue
-				}
-				if id.TagID.URI() == link.Href {
-					recursivePlantUML(stringBuilder, beenThere[:], uid, index)
-					stringBuilder.WriteByte('"')
-					stringBuilder.WriteString(strconv.Itoa(index))
-					stringBuilder.WriteString(`" --> "`)
-					stringBuilder.WriteString(strconv.Itoa(i))
-					stringBuilder.WriteByte('"')
-					stringBuilder.WriteString("\n")
-				}
-			}
+	var size int64
+	if i.Size != "" {
+		if size, err = strconv.ParseInt(i.Size, 0, 0); err != nil {
+			return fmt.Errorf("invalid size: %w", err)
 		}
 	}
+	var region uswid.Region
+	switch {
+	case i.Offset != "":
+		offset, err := strconv.ParseInt(i.Offset, 0, 0)
+		if err != nil {
+			return fmt.Errorf("invalid offset: %w", err)
+		}
+		region = uswid.Region{Offset: int(offset), Size: int(size)}
+	case i.Placeholder != "":
+		pattern, err := hex.DecodeString(i.Placeholder)
+		if err != nil {
+			return fmt.Errorf("invalid placeholder: %w", err)
+		}
+		if region, err = uswid.FindPlaceholder(image, pattern); err != nil {
+			return err
+		}
+	default:
+		if region, err = uswid.FindBlobRegion(image, i.Index); err != nil {
+			return err
+		}
+		if i.Size != "" {
+			region.Size = int(size)
+		}
+	}
+	if err := uswid.Inject(image, region, blob, byte(pad)); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d bytes uSWID data to region %#x-%#x\n", len(blob), region.Offset, region.End())
+
+	outputFile := i.OutputFile
+	if outputFile == "" {
+		outputFile = i.Image
+	}
+	return writeBinary(outputFile, image, i.Image)
 }
 
-func ToPlantUML(id *uswid.UswidSoftwareIdentity) ([]byte, error) {
-	var strbuilder strings.Builder
-	beenThere := make([]bool, len(id.Identities))
-	strbuilder.WriteString("@startuml\n")
-	for i, been := range beenThere {
-		if been {
-			continue
-		}
-		recursivePlantUML(&strbuilder, beenThere[:], id, i)
+func (s *stripCmd) Run() error {
+	pad, err := strconv.ParseUint(s.Pad, 0, 8)
+	if err != nil {
+		return fmt.Errorf("invalid pad byte: %w", err)
+	}
+	mode := uswid.StripErase
+	if s.Mode == "truncate" {
+		mode = uswid.StripTruncate
+	}
+	image, err := ioutil.ReadFile(s.Image)
+	if err != nil {
+		return err
+	}
+	regions, err := uswid.Strip(image, mode, byte(pad))
+	if err != nil {
+		return err
+	}
+	if len(regions) == 0 {
+		return uswid.ErrNotFound
+	}
+	for _, r := range regions {
+		fmt.Fprintf(os.Stderr, "stripped uSWID blob at %#x-%#x\n", r.Offset, r.End())
+	}
+
+	outputFile := s.OutputFile
+	if outputFile == "" {
+		outputFile = s.Image
+	}
+	return writeBinary(outputFile, image, s.Image)
+}
+
+func (e *embedCmd) Run() error {
+	compression, err := uswid.ParseCompression(e.Compression)
+	if err != nil {
+		return err
+	}
+	utag, err := importFiles("", e.InputTags, nil, nil, uswid.CodecOptions{})
+	if err != nil {
+		return err
+	}
+	blob, err := utag.ToUSWIDOptions(uswid.USWIDOptions{HeaderVersion: e.HeaderVersion, Compression: compression})
+	if err != nil {
+		return err
+	}
+	binary, err := ioutil.ReadFile(e.Binary)
+	if err != nil {
+		return err
+	}
+	var out []byte
+	if pesection.IsPE(bytes.NewReader(binary)) {
+		// PE sections can't be moved around easily, only append a new one
+		out, err = pesection.Append(binary, e.Section, blob)
+	} else {
+		out, err = elfsection.Set(binary, e.Section, blob)
 	}
-	strbuilder.WriteString("@enduml")
-	return []byte(strbuilder.String()), nil
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.Binary, err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d bytes uSWID data to section %s\n", len(blob), e.Section)
+
+	outputFile := e.OutputFile
+	if outputFile == "" {
+		outputFile = e.Binary
+	}
+	return writeBinary(outputFile, out, e.Binary)
 }
 
-func writeFile(filename string, fileFormat string, zlibCompress bool, utag uswid.UswidSoftwareIdentity) error {
-	// check file extension and put CoSWID tags into output file
-	var output_buf []byte
+// writeBinary writes data to filename with the permissions of inputFile, as
+// binaries and images are often executable.
+func writeBinary(filename string, data []byte, inputFile string) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(inputFile); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	return ioutil.WriteFile(filename, data, mode)
+}
 
-	var err error
-	switch fileFormat {
-	case "json":
-		output_buf, err = utag.ToJSON()
+func (v *validateCmd) Run() error {
+	opts, err := fileOptions(v.InputFormat, v.Region, v.Section, v.CBFSFile)
+	if err != nil {
+		return err
+	}
+	var count int
+	for _, file := range v.InputTags {
+		violations, err := validateEncoding(file, opts)
+		if err != nil {
+			return err
+		}
+		var utag uswid.UswidSoftwareIdentity
+		if err := utag.FromFileOptions(file, opts); err != nil {
+			// the schema violations tell what is wrong with XML files
+			// in more detail
+			if len(violations) == 0 {
+				return err
+			}
+		} else {
+			violations = append(utag.Validate(), violations...)
+		}
+		for _, violation := range violations {
+			fmt.Printf("%s: %s\n", file, violation)
+			count++
+		}
+	}
+	if count != 0 {
+		return fmt.Errorf("%d violations found", count)
+	}
+	return nil
+}
+
+// validateEncoding checks SWID XML files against the ISO/IEC 19770-2:2015
+// schema rules, and the map keys of CBOR and uSWID files. Only these files
+// are read completely, images are only sniffed.
+func validateEncoding(file string, opts uswid.CodecOptions) ([]uswid.Violation, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	format := opts.Format
+	if format == "" {
+		head := make([]byte, uswid.SniffLen)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, err
+		}
+		codec, err := uswid.SniffCodec(head[:n])
+		if err != nil {
+			if codec, err = uswid.CodecByExtension(file); err != nil {
+				// decoding reports the unknown format
+				return nil, nil
+			}
+		}
+		format = codec.Name()
+	}
+	var validate func([]byte) []uswid.Violation
+	switch format {
 	case "xml":
-		output_buf, err = utag.ToXML()
+		validate = uswid.ValidateXML
 	case "cbor":
-		output_buf, err = utag.ToCBOR(zlibCompress)
+		validate = uswid.ValidateCBOR
 	case "uswid":
-		output_buf, err = utag.ToUSWID(zlibCompress)
-	case "plantuml":
-		output_buf, err = ToPlantUML(&utag)
-	case "":
-		of_parts := strings.Split(filename, ".")
-		if len(of_parts) < 2 {
-			return errors.New("no file extension found")
-		}
-		switch of_parts[len(of_parts)-1] {
-		case "json":
-			output_buf, err = utag.ToJSON()
-		case "xml":
-			output_buf, err = utag.ToXML()
-		case "cbor":
-			output_buf, err = utag.ToCBOR(zlibCompress)
-		case "uswid":
-			output_buf, err = utag.ToUSWID(zlibCompress)
-		case "plantuml":
-			output_buf, err = ToPlantUML(&utag)
-		default:
-			return errors.New("could not guess file format by file extension")
-		}
+		validate = uswid.ValidateUSWID
 	default:
-		return errors.New("unknown file format")
+		return nil, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return validate(data), nil
+}
+
+func (c *cbfsListCmd) Run() error {
+	f, err := os.Open(c.Image)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	files, err := cbfs.Parse(f, fi.Size())
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Name\tOffset\tType\tSize\tCompression")
+	for _, file := range files {
+		name := file.Name
+		if file.Type == cbfs.TypeNull {
+			name = "(empty)"
+		}
+		compression := file.Compression.String()
+		if file.Compression != cbfs.CompressionNone {
+			compression = fmt.Sprintf("%s (%d decompressed)", compression, file.DecompressedSize)
+		}
+		fmt.Fprintf(w, "%s\t%#x\t%s\t%d\t%s\n", name, file.Offset, file.Type, file.Size, compression)
+	}
+	return w.Flush()
+}
+
+func (g *generateTagIDCmd) Run() {
+	fmt.Println(uuid.NewSHA1(uuid.NameSpaceDNS, []byte(g.UuidgenName)))
+}
+
+func writeFile(filename string, fileFormat string, opts uswid.CodecOptions, utag uswid.UswidSoftwareIdentity, validate bool) error {
+	// take the format from --output-format or guess it from the file extension
+	var codec uswid.Codec
+	var err error
+	if fileFormat != "" {
+		codec, err = uswid.CodecByName(fileFormat)
+	} else {
+		codec, err = uswid.CodecByExtension(filename)
 	}
 	if err != nil {
 		return err
 	}
 
+	var output_buf bytes.Buffer
+	opts.Filename = filename
+	if err := codec.Encode(&output_buf, utag, opts); err != nil {
+		return fmt.Errorf("writing %s: %w", codec.Name(), err)
+	}
+	if validate {
+		violations := utag.Validate()
+		if codec.Name() == "xml" {
+			violations = append(violations, uswid.ValidateXML(output_buf.Bytes())...)
+		}
+		for _, violation := range violations {
+			fmt.Fprintln(os.Stderr, violation)
+		}
+		if len(violations) != 0 {
+			return fmt.Errorf("%d violations found, not writing %s", len(violations), filename)
+		}
+	}
+
 	if filename == "-" {
-		fmt.Print(output_buf)
+		if _, err := os.Stdout.Write(output_buf.Bytes()); err != nil {
+			return err
+		}
 	} else {
-		if err := ioutil.WriteFile(filename, output_buf, 0644); err != nil {
+		if err := ioutil.WriteFile(filename, output_buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listOffsets prints the offsets of all uSWID blobs and CBFS SBOM files found
+// in files to stderr, uSWID blobs in UEFI FFS files are listed with the GUID of
+// the file. If an image has a FMAP, the region holding the data is
+// printed as well. If region is not empty, only that FMAP region is searched.
+func listOffsets(files []string, opts uswid.CodecOptions) error {
+	var img uswid.ImageOptions
+	opts.Option(&img)
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		isImage, err := isImageFile(file, opts.Format)
+		if err != nil {
+			return err
+		}
+		region := img.Region
+		if !isImage {
+			// only flash images have a FMAP
+			region = ""
+		}
+		if err := listImageOffsets(file, region, img.CBFSFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isImageFile reports whether file is a binary image, which is either given
+// by the format name or detected from the file content.
+func isImageFile(file string, format string) (bool, error) {
+	if format == "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		head := make([]byte, uswid.SniffLen)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return false, err
+		}
+		codec, err := uswid.SniffCodec(head[:n])
+		if err != nil {
+			return false, nil
+		}
+		format = codec.Name()
+	}
+	return format == "image", nil
+}
+
+// printRegions pretty prints the tags of the flash image file as JSON object,
+// which maps the FMAP region names to the tags found in the region.
+func printRegions(file string, opts uswid.CodecOptions) error {
+	var img uswid.ImageOptions
+	opts.Option(&img)
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	regions, err := uswid.ImageRegions(f, fi.Size(), img)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, r := range regions {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		name, err := json.Marshal(r.Region)
+		if err != nil {
 			return err
 		}
+		out.Write(name)
+		out.WriteString(":[")
+		for j, id := range r.Identities {
+			if j > 0 {
+				out.WriteByte(',')
+			}
+			buf, err := id.ToJSON()
+			if err != nil {
+				return fmt.Errorf("convert to JSON: %w", err)
+			}
+			out.Write(buf)
+		}
+		out.WriteByte(']')
+	}
+	out.WriteByte('}')
+	var prettyJSON bytes.Buffer
+	if err := json.Indent(&prettyJSON, out.Bytes(), "", "    "); err != nil {
+		return err
+	}
+	fmt.Println(prettyJSON.String())
+	return nil
+}
+
+func listImageOffsets(file string, region string, cbfsFile string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	section, base, err := uswid.ImageSection(f, fi.Size(), region)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	// the FMAP is optional, only use it to name the regions
+	layout, _ := fmap.Find(f, fi.Size())
+	regionOf := func(offset int64) string {
+		if layout == nil {
+			return ""
+		}
+		if a := layout.AreaAt(offset); a != nil {
+			return fmt.Sprintf(" in region %s", a.Name)
+		}
+		return ""
+	}
+
+	// the magic scan below still works if CBFS or firmware volumes are
+	// broken, so only warn about them
+	files, err := cbfs.Parse(section, section.Size())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: parsing CBFS: %v\n", file, err)
+	}
+	for _, c := range cbfs.Find(files, cbfsFile) {
+		fmt.Fprintf(os.Stderr, "%s: CBFS file %q at offset %#x%s\n", file, c.Name, base+c.Offset, regionOf(base+c.Offset))
+	}
+
+	var utag uswid.UswidSoftwareIdentity
+	ffs, err := utag.FromUEFI(section, section.Size())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: parsing UEFI firmware volumes: %v\n", file, err)
+	}
+	for _, b := range ffs {
+		fmt.Fprintf(os.Stderr, "%s: uSWID blob in FFS file %s (header version %d, %d bytes payload, %s compression)\n",
+			file, b.File, b.Header.Version, b.Header.PayloadSize, b.Header.Compression)
+		for _, id := range b.Identities {
+			fmt.Fprintf(os.Stderr, "%s:     %s %s (tag-id %s)\n", file, id.SoftwareName, id.SoftwareVersion, id.TagID.String())
+		}
+	}
+
+	s := uswid.NewScanner(section)
+	for s.Next() {
+		b := s.Blob()
+		offset := base + int64(b.Offset)
+		fmt.Fprintf(os.Stderr, "%s: uSWID blob at offset %#x%s (header version %d, %d bytes payload, %s compression)\n",
+			file, offset, regionOf(offset), b.Header.Version, b.Header.PayloadSize, b.Header.Compression)
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("scanning %s: %w", file, err)
 	}
 	return nil
 }
 
-func importFiles(parentTag string, inputFiles []string, requiredTags []string, compilerTags []string) (*uswid.UswidSoftwareIdentity, error) {
+func fileOptions(inputFormat string, region string, section string, cbfsFile string) (uswid.CodecOptions, error) {
+	opts := uswid.CodecOptions{Options: []interface{}{uswid.ImageOptions{Region: region, Section: section, CBFSFile: cbfsFile}}}
+	if inputFormat != "" {
+		codec, err := uswid.CodecByName(inputFormat)
+		if err != nil {
+			return opts, err
+		}
+		opts.Format = codec.Name()
+	}
+	return opts, nil
+}
+
+func importFiles(parentTag string, inputFiles []string, requiredTags []string, compilerTags []string, opts uswid.CodecOptions) (*uswid.UswidSoftwareIdentity, error) {
 	var utag uswid.UswidSoftwareIdentity
 	if parentTag != "" {
-		if err := utag.FromFile(parentTag); err != nil {
+		if err := utag.FromFileOptions(parentTag, opts); err != nil {
 			return nil, err
 		}
 	}
 	for _, input_file_path := range requiredTags {
 		index := len(utag.Identities)
-		if err := utag.FromFile(input_file_path); err != nil {
+		if err := utag.FromFileOptions(input_file_path, opts); err != nil {
 			return nil, err
 		}
 
@@ -290,7 +769,7 @@ func importFiles(parentTag string, inputFiles []string, requiredTags []string, c
 	}
 	for _, input_file_path := range compilerTags {
 		index := len(utag.Identities)
-		if err := utag.FromFile(input_file_path); err != nil {
+		if err := utag.FromFileOptions(input_file_path, opts); err != nil {
 			return nil, err
 		}
 
@@ -304,7 +783,7 @@ func importFiles(parentTag string, inputFiles []string, requiredTags []string, c
 		}
 	}
 	for _, input_file_path := range inputFiles {
-		if err := utag.FromFile(input_file_path); err != nil {
+		if err := utag.FromFileOptions(input_file_path, opts); err != nil {
 			return nil, err
 		}
 	}
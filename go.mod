@@ -7,6 +7,7 @@ require (
 	github.com/alecthomas/kong v0.5.0
 	github.com/fxamacker/cbor/v2 v2.3.0
 	github.com/google/uuid v1.3.0
+	github.com/ulikunitz/xz v0.5.11
 )
 
 require (
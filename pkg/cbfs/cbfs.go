@@ -0,0 +1,324 @@
+// Package cbfs implements a small reader for the coreboot file system (CBFS),
+// which is used by coreboot to store stages, payloads and other files (like
+// the SBOM) in the flash image.
+package cbfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// all CBFS files start at an offset aligned to this value
+const Alignment = 64
+
+// DefaultSBOMName is the name coreboot uses for the CBFS file containing
+// the uSWID SBOM data.
+const DefaultSBOMName = "sbom"
+
+var fileMagic = []byte("LARCHIVE")
+
+// HeaderMagic is the magic value of the CBFS master header ("ORBC").
+const HeaderMagic = 0x4F524243
+
+const fileHeaderSize = 24
+
+// largest dictionary size of the lzma presets
+const maxLZMADictCap = 64 << 20
+
+// Type is the type of a CBFS file.
+type Type uint32
+
+const (
+	TypeDeleted     Type = 0x00000000
+	TypeBootblock   Type = 0x01
+	TypeCBFSHeader  Type = 0x02
+	TypeLegacyStage Type = 0x10
+	TypeStage       Type = 0x11
+	TypeSELF        Type = 0x20
+	TypeFIT         Type = 0x21
+	TypeOptionROM   Type = 0x30
+	TypeBootsplash  Type = 0x40
+	TypeRaw         Type = 0x50
+	TypeVSA         Type = 0x51
+	TypeMBI         Type = 0x52
+	TypeMicrocode   Type = 0x53
+	TypeFSP         Type = 0x60
+	TypeMRC         Type = 0x61
+	TypeMMA         Type = 0x62
+	TypeEFI         Type = 0x63
+	TypeStruct      Type = 0x70
+	TypeCMOSDefault Type = 0xaa
+	TypeSPD         Type = 0xab
+	TypeMRCCache    Type = 0xac
+	TypeCMOSLayout  Type = 0x01aa
+	TypeNull        Type = 0xffffffff
+)
+
+var typeNames = map[Type]string{
+	TypeDeleted:     "deleted",
+	TypeBootblock:   "bootblock",
+	TypeCBFSHeader:  "cbfs header",
+	TypeLegacyStage: "legacy stage",
+	TypeStage:       "stage",
+	TypeSELF:        "simple elf",
+	TypeFIT:         "fit_payload",
+	TypeOptionROM:   "optionrom",
+	TypeBootsplash:  "bootsplash",
+	TypeRaw:         "raw",
+	TypeVSA:         "vsa",
+	TypeMBI:         "mbi",
+	TypeMicrocode:   "microcode",
+	TypeFSP:         "fsp",
+	TypeMRC:         "mrc",
+	TypeMMA:         "mma",
+	TypeEFI:         "efi",
+	TypeStruct:      "struct",
+	TypeCMOSDefault: "cmos_default",
+	TypeSPD:         "spd",
+	TypeMRCCache:    "mrc_cache",
+	TypeCMOSLayout:  "cmos_layout",
+	TypeNull:        "null",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type(%#x)", uint32(t))
+}
+
+// Compression is the compression algorithm of a CBFS file as stored in its
+// compression attribute.
+type Compression uint32
+
+const (
+	CompressionNone Compression = 0
+	CompressionLZMA Compression = 1
+	CompressionLZ4  Compression = 2
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionLZMA:
+		return "LZMA"
+	case CompressionLZ4:
+		return "LZ4"
+	default:
+		return fmt.Sprintf("compression(%d)", uint32(c))
+	}
+}
+
+// file attribute tags
+const (
+	attrTagUnused      = 0
+	attrTagUnused2     = 0xffffffff
+	attrTagCompression = 0x42435a4c
+)
+
+// MasterHeader is the CBFS master header (struct cbfs_header). Newer coreboot
+// versions only keep it for compatibility in the "cbfs master header" file.
+type MasterHeader struct {
+	Magic         uint32
+	Version       uint32
+	ROMSize       uint32
+	BootblockSize uint32
+	Align         uint32
+	Offset        uint32
+	Architecture  uint32
+	Pad           uint32
+}
+
+// File is a single file of a CBFS.
+type File struct {
+	Offset           int64 // offset of the file header in the image
+	DataOffset       int64 // offset of the file data in the image
+	Name             string
+	Type             Type
+	Size             uint32 // size of the (compressed) data
+	Compression      Compression
+	DecompressedSize uint32
+
+	r io.ReaderAt
+}
+
+// Data returns the raw data of the file as stored in the CBFS.
+func (f File) Data() ([]byte, error) {
+	data := make([]byte, f.Size)
+	if _, err := f.r.ReadAt(data, f.DataOffset); err != nil {
+		return nil, fmt.Errorf("reading CBFS file %q: %w", f.Name, err)
+	}
+	return data, nil
+}
+
+// Content returns the decompressed data of the file.
+func (f File) Content() ([]byte, error) {
+	data, err := f.Data()
+	if err != nil {
+		return nil, err
+	}
+	switch f.Compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionLZMA:
+		// the lzma reader allocates the dictionary size from the stream
+		// header up front, don't let a broken header make it allocate GiBs
+		if len(data) >= lzma.HeaderLen && binary.LittleEndian.Uint32(data[1:5]) > maxLZMADictCap {
+			return nil, fmt.Errorf("CBFS file %q: lzma dictionary size too large", f.Name)
+		}
+		rd, err := lzma.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("CBFS file %q: %w", f.Name, err)
+		}
+		var out bytes.Buffer
+		if _, err := io.CopyN(&out, rd, int64(f.DecompressedSize)); err != nil {
+			return nil, fmt.Errorf("CBFS file %q: lzma: %w", f.Name, err)
+		}
+		return out.Bytes(), nil
+	case CompressionLZ4:
+		out, err := decompressLZ4(data, int(f.DecompressedSize))
+		if err != nil {
+			return nil, fmt.Errorf("CBFS file %q: lz4: %w", f.Name, err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("CBFS file %q: unknown compression %s", f.Name, f.Compression)
+	}
+}
+
+// MasterHeader decodes the content of a "cbfs master header" file.
+func (f File) MasterHeader() (*MasterHeader, error) {
+	if f.Type != TypeCBFSHeader {
+		return nil, fmt.Errorf("CBFS file %q is no master header but %s", f.Name, f.Type)
+	}
+	data, err := f.Data()
+	if err != nil {
+		return nil, err
+	}
+	var h MasterHeader
+	if err := binary.Read(bytes.NewReader(data), binary.BigEndian, &h); err != nil {
+		return nil, fmt.Errorf("decoding CBFS master header: %w", err)
+	}
+	if h.Magic != HeaderMagic {
+		return nil, fmt.Errorf("invalid CBFS master header magic %#x", h.Magic)
+	}
+	return &h, nil
+}
+
+// parseFile parses the file header at offset.
+func parseFile(r io.ReaderAt, size int64, offset int64) (File, error) {
+	f := File{Offset: offset, r: r}
+	header := make([]byte, fileHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return f, fmt.Errorf("reading CBFS file header at %#x: %w", offset, err)
+	}
+	f.Size = binary.BigEndian.Uint32(header[8:12])
+	f.Type = Type(binary.BigEndian.Uint32(header[12:16]))
+	attrOffset := binary.BigEndian.Uint32(header[16:20])
+	dataOffset := binary.BigEndian.Uint32(header[20:24])
+	if dataOffset < fileHeaderSize || int64(dataOffset) > size-offset {
+		return f, fmt.Errorf("CBFS file header at %#x: invalid data offset %#x", offset, dataOffset)
+	}
+	if int64(f.Size) > size-offset-int64(dataOffset) {
+		return f, fmt.Errorf("CBFS file header at %#x: file exceeds image", offset)
+	}
+	f.DataOffset = offset + int64(dataOffset)
+
+	// file name and attributes sit between the fixed header and the data
+	meta := make([]byte, dataOffset-fileHeaderSize)
+	if _, err := r.ReadAt(meta, offset+fileHeaderSize); err != nil {
+		return f, fmt.Errorf("reading CBFS file header at %#x: %w", offset, err)
+	}
+	nameEnd := len(meta)
+	if attrOffset >= fileHeaderSize && attrOffset < dataOffset {
+		nameEnd = int(attrOffset - fileHeaderSize)
+		parseAttributes(&f, meta[nameEnd:])
+	}
+	f.Name = string(meta[:nameEnd])
+	if i := strings.IndexByte(f.Name, 0); i != -1 {
+		f.Name = f.Name[:i]
+	}
+	return f, nil
+}
+
+func parseAttributes(f *File, attrs []byte) {
+	for len(attrs) >= 8 {
+		tag := binary.BigEndian.Uint32(attrs[0:4])
+		length := binary.BigEndian.Uint32(attrs[4:8])
+		if tag == attrTagUnused || tag == attrTagUnused2 || length < 8 || int(length) > len(attrs) {
+			return
+		}
+		if tag == attrTagCompression && length >= 16 {
+			f.Compression = Compression(binary.BigEndian.Uint32(attrs[8:12]))
+			f.DecompressedSize = binary.BigEndian.Uint32(attrs[12:16])
+		}
+		attrs = attrs[length:]
+	}
+}
+
+// Parse returns all CBFS files found in the first size bytes of r. There may
+// be several CBFS in one image (e.g. the COREBOOT, FW_MAIN_A and FW_MAIN_B
+// FMAP regions), the files of all of them are returned. Only the file headers
+// are read, use File.Data or File.Content to read the file data.
+func Parse(r io.ReaderAt, size int64) ([]File, error) {
+	var files []File
+	chunk := make([]byte, 1<<20)
+	for pos := int64(0); pos < size; {
+		n, err := r.ReadAt(chunk, pos)
+		if err != nil && err != io.EOF {
+			return files, err
+		}
+		if n < len(fileMagic) {
+			break
+		}
+		next := pos + int64(n-len(fileMagic)+1)
+		for i := 0; ; {
+			j := bytes.Index(chunk[i:n], fileMagic)
+			if j == -1 {
+				break
+			}
+			offset := pos + int64(i+j)
+			i += j + 1
+			if offset%Alignment != 0 {
+				continue
+			}
+			if size-offset < fileHeaderSize {
+				break
+			}
+			f, err := parseFile(r, size, offset)
+			if err != nil {
+				// LARCHIVE might just show up in some file data, keep on
+				// searching
+				continue
+			}
+			files = append(files, f)
+			// don't search the file data for more files
+			end := f.DataOffset + int64(f.Size)
+			if end > pos+int64(n) {
+				next = end
+				break
+			}
+			i = int(end - pos)
+		}
+		pos = next
+	}
+	return files, nil
+}
+
+// Find returns all files named name. An image may contain several CBFS, so
+// there may be more than one.
+func Find(files []File, name string) []File {
+	var found []File
+	for _, f := range files {
+		if f.Name == name {
+			found = append(found, f)
+		}
+	}
+	return found
+}
@@ -0,0 +1,249 @@
+package cbfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+type fixtureFile struct {
+	name        string
+	typ         Type
+	compression Compression
+	size        uint32 // decompressed size, only with compression
+	data        []byte
+}
+
+// buildCBFS generates a CBFS holding files, each one aligned to Alignment
+// and followed by padding of 0xff bytes like in a flash image.
+func buildCBFS(t testing.TB, files []fixtureFile) []byte {
+	t.Helper()
+	var image []byte
+	for _, f := range files {
+		for len(image)%Alignment != 0 {
+			image = append(image, 0xff)
+		}
+		name := append([]byte(f.name), 0)
+		for len(name)%16 != 0 {
+			name = append(name, 0)
+		}
+		var attrs []byte
+		if f.compression != CompressionNone {
+			attrs = make([]byte, 16)
+			binary.BigEndian.PutUint32(attrs[0:], attrTagCompression)
+			binary.BigEndian.PutUint32(attrs[4:], uint32(len(attrs)))
+			binary.BigEndian.PutUint32(attrs[8:], uint32(f.compression))
+			binary.BigEndian.PutUint32(attrs[12:], f.size)
+		}
+		attrOffset := uint32(0)
+		if attrs != nil {
+			attrOffset = uint32(fileHeaderSize + len(name))
+		}
+		header := make([]byte, fileHeaderSize)
+		copy(header, fileMagic)
+		binary.BigEndian.PutUint32(header[8:], uint32(len(f.data)))
+		binary.BigEndian.PutUint32(header[12:], uint32(f.typ))
+		binary.BigEndian.PutUint32(header[16:], attrOffset)
+		binary.BigEndian.PutUint32(header[20:], uint32(fileHeaderSize+len(name)+len(attrs)))
+		image = append(image, header...)
+		image = append(image, name...)
+		image = append(image, attrs...)
+		image = append(image, f.data...)
+	}
+	for len(image)%Alignment != 0 {
+		image = append(image, 0xff)
+	}
+	return image
+}
+
+func masterHeader(t testing.TB) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, MasterHeader{Magic: HeaderMagic, Version: 0x31313132, ROMSize: 1 << 20, Align: Alignment})
+	return b.Bytes()
+}
+
+func compressLZMA(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := lzma.WriterConfig{Size: int64(len(data))}.NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// the LZ4 frame of lz4Content: "abc", a match of 16 bytes at offset 3 and
+// the literals "bcabc"
+var (
+	lz4Content = bytes.Repeat([]byte("abc"), 8)
+	lz4Frame   = []byte{
+		0x04, 0x22, 0x4d, 0x18, 0x40, 0x40, 0x00, // magic, FLG, BD, HC
+		12, 0, 0, 0, // block size
+		0x3c, 'a', 'b', 'c', 3, 0,
+		0x50, 'b', 'c', 'a', 'b', 'c',
+		0, 0, 0, 0, // end mark
+	}
+)
+
+var fixtureContent = []byte("uSWID data of the firmware")
+
+func fixtureFiles(t testing.TB) []fixtureFile {
+	return []fixtureFile{
+		{name: "cbfs master header", typ: TypeCBFSHeader, data: masterHeader(t)},
+		// an aligned LARCHIVE in the data of a file is no file header
+		{name: "fallback/romstage", typ: TypeStage, data: append(make([]byte, 72), "LARCHIVE\x00\x00\x00\x08\x00\x00\x00\x50\x00\x00\x00\x00\x00\x00\x00\x18"...)},
+		{name: DefaultSBOMName, typ: TypeRaw, data: fixtureContent},
+		{name: "sbom-lzma", typ: TypeRaw, compression: CompressionLZMA, size: uint32(len(fixtureContent)), data: compressLZMA(t, fixtureContent)},
+		{name: "sbom-lz4", typ: TypeRaw, compression: CompressionLZ4, size: uint32(len(lz4Content)), data: lz4Frame},
+	}
+}
+
+func TestParse(t *testing.T) {
+	image := buildCBFS(t, fixtureFiles(t))
+	files, err := Parse(bytes.NewReader(image), int64(len(image)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name        string
+		typ         Type
+		compression Compression
+	}{
+		{"cbfs master header", TypeCBFSHeader, CompressionNone},
+		{"fallback/romstage", TypeStage, CompressionNone},
+		{DefaultSBOMName, TypeRaw, CompressionNone},
+		{"sbom-lzma", TypeRaw, CompressionLZMA},
+		{"sbom-lz4", TypeRaw, CompressionLZ4},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("got %d files, want %d: %+v", len(files), len(want), files)
+	}
+	for i, w := range want {
+		f := files[i]
+		if f.Name != w.name || f.Type != w.typ || f.Compression != w.compression {
+			t.Errorf("file %d = %q %s %s, want %q %s %s", i, f.Name, f.Type, f.Compression, w.name, w.typ, w.compression)
+		}
+		if f.Offset%Alignment != 0 {
+			t.Errorf("file %q at unaligned offset %#x", f.Name, f.Offset)
+		}
+	}
+
+	h, err := files[0].MasterHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.ROMSize != 1<<20 || h.Align != Alignment {
+		t.Errorf("master header = %+v", h)
+	}
+	if _, err := files[2].MasterHeader(); err == nil {
+		t.Error("MasterHeader of a raw file succeeded")
+	}
+
+	if found := Find(files, DefaultSBOMName); len(found) != 1 || found[0].Offset != files[2].Offset {
+		t.Errorf("Find(%q) = %+v", DefaultSBOMName, found)
+	}
+}
+
+func TestContent(t *testing.T) {
+	image := buildCBFS(t, fixtureFiles(t))
+	files, err := Parse(bytes.NewReader(image), int64(len(image)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name string
+		want []byte
+	}{
+		{DefaultSBOMName, fixtureContent},
+		{"sbom-lzma", fixtureContent},
+		{"sbom-lz4", lz4Content},
+	} {
+		found := Find(files, test.name)
+		if len(found) != 1 {
+			t.Fatalf("Find(%q) = %+v", test.name, found)
+		}
+		got, err := found[0].Content()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: Content = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestContentCorrupt(t *testing.T) {
+	lzmaData := compressLZMA(t, fixtureContent)
+	hugeDict := append([]byte{}, lzmaData...)
+	binary.LittleEndian.PutUint32(hugeDict[1:5], maxLZMADictCap+1)
+	for _, f := range []fixtureFile{
+		{name: "truncated lzma", compression: CompressionLZMA, size: uint32(len(fixtureContent)), data: lzmaData[:len(lzmaData)/2]},
+		{name: "huge dictionary", compression: CompressionLZMA, size: uint32(len(fixtureContent)), data: hugeDict},
+		{name: "truncated lz4", compression: CompressionLZ4, size: uint32(len(lz4Content)), data: lz4Frame[:len(lz4Frame)-6]},
+		{name: "lz4 too large", compression: CompressionLZ4, size: uint32(len(lz4Content) - 1), data: lz4Frame},
+		{name: "unknown compression", compression: 7, size: 1, data: []byte{0}},
+	} {
+		f.typ = TypeRaw
+		image := buildCBFS(t, []fixtureFile{f})
+		files, err := Parse(bytes.NewReader(image), int64(len(image)))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("%s: Parse = %+v, %v", f.name, files, err)
+		}
+		if got, err := files[0].Content(); err == nil {
+			t.Errorf("%s: Content = %q, want error", f.name, got)
+		}
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	image := buildCBFS(t, fixtureFiles(t))
+	last := buildCBFS(t, fixtureFiles(t)[:4])
+	for _, size := range []int{
+		len(last) + fileHeaderSize - 1, // within the header of the last file
+		len(last) + fileHeaderSize + 4, // within its name
+		len(image) - 64,                // within its data
+	} {
+		files, err := Parse(bytes.NewReader(image[:size]), int64(size))
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if len(files) != 4 {
+			t.Errorf("%d bytes: got %d files, want the 4 complete ones", size, len(files))
+		}
+	}
+	// the size limits the search even if the reader holds more
+	files, err := Parse(bytes.NewReader(image), int64(len(last)+fileHeaderSize))
+	if err != nil || len(files) != 4 {
+		t.Errorf("Parse of a size within the last file = %d files, %v", len(files), err)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	image := buildCBFS(f, fixtureFiles(f))
+	f.Add(image)
+	f.Add(image[:len(image)/2])
+	f.Add(image[:fileHeaderSize])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		files, err := Parse(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		for _, file := range files {
+			if file.Offset%Alignment != 0 || file.DataOffset+int64(file.Size) > int64(len(data)) {
+				t.Errorf("file %q at %#x with %d bytes of data at %#x exceeds %d bytes", file.Name, file.Offset, file.Size, file.DataOffset, len(data))
+			}
+			file.Content()
+			file.MasterHeader()
+		}
+	})
+}
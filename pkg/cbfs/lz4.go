@@ -0,0 +1,130 @@
+package cbfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// coreboot compresses CBFS files with the LZ4 frame format. Only what is
+// needed to decompress them is implemented here, checksums are not verified.
+
+const lz4FrameMagic = 0x184D2204
+
+var errLZ4Corrupt = errors.New("corrupt input")
+
+// decompressLZ4 decompresses an LZ4 frame. The output is limited to maxSize
+// bytes.
+func decompressLZ4(src []byte, maxSize int) ([]byte, error) {
+	if len(src) < 7 || binary.LittleEndian.Uint32(src[0:4]) != lz4FrameMagic {
+		return nil, errors.New("no LZ4 frame")
+	}
+	flg := src[4]
+	if flg>>6 != 1 {
+		return nil, fmt.Errorf("unsupported LZ4 frame version %d", flg>>6)
+	}
+	blockChecksum := flg&0x10 != 0
+	pos := 6 // magic, FLG, BD
+	if flg&0x08 != 0 {
+		pos += 8 // content size
+	}
+	if flg&0x01 != 0 {
+		pos += 4 // dictionary ID
+	}
+	pos++ // header checksum
+
+	dst := make([]byte, 0, maxSize)
+	for {
+		if pos+4 > len(src) {
+			return nil, errLZ4Corrupt
+		}
+		blockSize := binary.LittleEndian.Uint32(src[pos : pos+4])
+		pos += 4
+		if blockSize == 0 {
+			// end mark, an optional content checksum may follow
+			return dst, nil
+		}
+		uncompressed := blockSize&0x80000000 != 0
+		blockSize &= 0x7FFFFFFF
+		if int(blockSize) > len(src)-pos {
+			return nil, errLZ4Corrupt
+		}
+		block := src[pos : pos+int(blockSize)]
+		pos += int(blockSize)
+		if blockChecksum {
+			pos += 4
+		}
+		var err error
+		if uncompressed {
+			if len(dst)+len(block) > maxSize {
+				return nil, errors.New("output exceeds decompressed size")
+			}
+			dst = append(dst, block...)
+		} else if dst, err = decompressLZ4Block(dst, block, maxSize); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decompressLZ4Block appends the decompressed block to dst. Matches may refer
+// to data of previous blocks in dst.
+func decompressLZ4Block(dst, src []byte, maxSize int) ([]byte, error) {
+	readLength := func(pos int, length int) (int, int, error) {
+		for {
+			if pos >= len(src) {
+				return 0, 0, errLZ4Corrupt
+			}
+			b := src[pos]
+			pos++
+			length += int(b)
+			if b != 255 {
+				return pos, length, nil
+			}
+		}
+	}
+
+	pos := 0
+	for pos < len(src) {
+		token := src[pos]
+		pos++
+		var err error
+
+		literals := int(token >> 4)
+		if literals == 15 {
+			if pos, literals, err = readLength(pos, literals); err != nil {
+				return nil, err
+			}
+		}
+		if literals > len(src)-pos || len(dst)+literals > maxSize {
+			return nil, errLZ4Corrupt
+		}
+		dst = append(dst, src[pos:pos+literals]...)
+		pos += literals
+		if pos == len(src) {
+			// the last sequence has no match
+			break
+		}
+
+		if pos+2 > len(src) {
+			return nil, errLZ4Corrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[pos : pos+2]))
+		pos += 2
+		matchLen := int(token & 0x0F)
+		if matchLen == 15 {
+			if pos, matchLen, err = readLength(pos, matchLen); err != nil {
+				return nil, err
+			}
+		}
+		matchLen += 4
+		if offset == 0 || offset > len(dst) || len(dst)+matchLen > maxSize {
+			return nil, errLZ4Corrupt
+		}
+		// matches may overlap with the bytes they produce, copy byte-wise
+		start := len(dst) - offset
+		for i := 0; i < matchLen; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+	return dst, nil
+}
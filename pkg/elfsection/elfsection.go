@@ -0,0 +1,261 @@
+// Package elfsection reads and writes single sections of ELF files, like the
+// .sbom section holding the uSWID data of a binary.
+package elfsection
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultName is the name of the section holding the SBOM.
+const DefaultName = ".sbom"
+
+// ErrNotFound is returned if the ELF file has no section of the given name.
+var ErrNotFound = errors.New("ELF section not found")
+
+// IsELF reports whether r starts with the ELF magic value.
+func IsELF(r io.ReaderAt) bool {
+	ident := make([]byte, len(elf.ELFMAG))
+	if _, err := r.ReadAt(ident, 0); err != nil {
+		return false
+	}
+	return string(ident) == elf.ELFMAG
+}
+
+// Read returns the content of the section called name.
+func Read(r io.ReaderAt, name string) ([]byte, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	s := f.Section(name)
+	if s == nil || s.Type == elf.SHT_NOBITS {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	data, err := s.Data()
+	if err != nil {
+		return nil, fmt.Errorf("reading ELF section %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// sectionHeader is a section header of either ELF class.
+type sectionHeader struct {
+	Name      uint32
+	Type      uint32
+	Flags     uint64
+	Addr      uint64
+	Offset    uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Addralign uint64
+	Entsize   uint64
+}
+
+// layout knows where the fields of the file and section headers are stored
+// for the class and byte order of a file.
+type layout struct {
+	order binary.ByteOrder
+	is64  bool
+}
+
+func (l layout) sectionHeaderSize() int {
+	if l.is64 {
+		return 64
+	}
+	return 40
+}
+
+// sectionTable returns offset, entry size, number of entries and the index of
+// the section name table.
+func (l layout) sectionTable(image []byte) (uint64, int, int, int) {
+	if l.is64 {
+		return l.order.Uint64(image[0x28:]), int(l.order.Uint16(image[0x3a:])),
+			int(l.order.Uint16(image[0x3c:])), int(l.order.Uint16(image[0x3e:]))
+	}
+	return uint64(l.order.Uint32(image[0x20:])), int(l.order.Uint16(image[0x2e:])),
+		int(l.order.Uint16(image[0x30:])), int(l.order.Uint16(image[0x32:]))
+}
+
+func (l layout) putSectionTable(image []byte, offset uint64, num int) {
+	if l.is64 {
+		l.order.PutUint64(image[0x28:], offset)
+		l.order.PutUint16(image[0x3c:], uint16(num))
+		return
+	}
+	l.order.PutUint32(image[0x20:], uint32(offset))
+	l.order.PutUint16(image[0x30:], uint16(num))
+}
+
+func (l layout) readSectionHeader(b []byte) sectionHeader {
+	if l.is64 {
+		return sectionHeader{
+			Name:      l.order.Uint32(b[0:]),
+			Type:      l.order.Uint32(b[4:]),
+			Flags:     l.order.Uint64(b[8:]),
+			Addr:      l.order.Uint64(b[16:]),
+			Offset:    l.order.Uint64(b[24:]),
+			Size:      l.order.Uint64(b[32:]),
+			Link:      l.order.Uint32(b[40:]),
+			Info:      l.order.Uint32(b[44:]),
+			Addralign: l.order.Uint64(b[48:]),
+			Entsize:   l.order.Uint64(b[56:]),
+		}
+	}
+	return sectionHeader{
+		Name:      l.order.Uint32(b[0:]),
+		Type:      l.order.Uint32(b[4:]),
+		Flags:     uint64(l.order.Uint32(b[8:])),
+		Addr:      uint64(l.order.Uint32(b[12:])),
+		Offset:    uint64(l.order.Uint32(b[16:])),
+		Size:      uint64(l.order.Uint32(b[20:])),
+		Link:      l.order.Uint32(b[24:]),
+		Info:      l.order.Uint32(b[28:]),
+		Addralign: uint64(l.order.Uint32(b[32:])),
+		Entsize:   uint64(l.order.Uint32(b[36:])),
+	}
+}
+
+func (l layout) putSectionHeader(b []byte, s sectionHeader) {
+	if l.is64 {
+		l.order.PutUint32(b[0:], s.Name)
+		l.order.PutUint32(b[4:], s.Type)
+		l.order.PutUint64(b[8:], s.Flags)
+		l.order.PutUint64(b[16:], s.Addr)
+		l.order.PutUint64(b[24:], s.Offset)
+		l.order.PutUint64(b[32:], s.Size)
+		l.order.PutUint32(b[40:], s.Link)
+		l.order.PutUint32(b[44:], s.Info)
+		l.order.PutUint64(b[48:], s.Addralign)
+		l.order.PutUint64(b[56:], s.Entsize)
+		return
+	}
+	l.order.PutUint32(b[0:], s.Name)
+	l.order.PutUint32(b[4:], s.Type)
+	l.order.PutUint32(b[8:], uint32(s.Flags))
+	l.order.PutUint32(b[12:], uint32(s.Addr))
+	l.order.PutUint32(b[16:], uint32(s.Offset))
+	l.order.PutUint32(b[20:], uint32(s.Size))
+	l.order.PutUint32(b[24:], s.Link)
+	l.order.PutUint32(b[28:], s.Info)
+	l.order.PutUint32(b[32:], uint32(s.Addralign))
+	l.order.PutUint32(b[36:], uint32(s.Entsize))
+}
+
+// appendAligned appends data to image at the next offset aligned to align and
+// returns the new image along with the offset of data.
+func appendAligned(image []byte, data []byte, align int) ([]byte, uint64) {
+	for len(image)%align != 0 {
+		image = append(image, 0)
+	}
+	return append(image, data...), uint64(len(image))
+}
+
+// Set replaces the content of the section called name with data, or adds a
+// new non-allocated section if there is none, and returns the new file. The
+// input image is not modified.
+//
+// Sections which are loaded at runtime can't be moved, their content is only
+// replaced if data fits and the remainder is zeroed. Other sections shrink
+// in place or are moved to the end of the file if data doesn't fit.
+func Set(image []byte, name string, data []byte) ([]byte, error) {
+	f, err := elf.NewFile(bytes.NewReader(image))
+	if err != nil {
+		return nil, err
+	}
+	l := layout{order: f.ByteOrder, is64: f.Class == elf.ELFCLASS64}
+	shoff, shentsize, shnum, shstrndx := l.sectionTable(image)
+	if shnum == 0 || shnum >= int(elf.SHN_LORESERVE) || shstrndx == int(elf.SHN_UNDEF) || shstrndx >= shnum {
+		return nil, errors.New("ELF files with extended section numbering or without section name table are not supported")
+	}
+	if shentsize != l.sectionHeaderSize() || shoff+uint64(shnum*shentsize) > uint64(len(image)) {
+		return nil, errors.New("invalid ELF section header table")
+	}
+	headers := make([]sectionHeader, shnum)
+	for i := range headers {
+		headers[i] = l.readSectionHeader(image[shoff+uint64(i*shentsize):])
+	}
+
+	out := append([]byte{}, image...)
+	index := -1
+	for i, s := range f.Sections {
+		if s.Name == name {
+			index = i
+			break
+		}
+	}
+	if index != -1 {
+		s := &headers[index]
+		if elf.SectionType(s.Type) == elf.SHT_NOBITS {
+			return nil, fmt.Errorf("ELF section %s has no data in the file", name)
+		}
+		if s.Offset+s.Size > uint64(len(out)) {
+			return nil, fmt.Errorf("ELF section %s exceeds file", name)
+		}
+		old := out[s.Offset : s.Offset+s.Size]
+		switch {
+		case uint64(len(data)) <= s.Size:
+			n := copy(old, data)
+			for i := n; i < len(old); i++ {
+				old[i] = 0
+			}
+			if elf.SectionFlag(s.Flags)&elf.SHF_ALLOC == 0 {
+				s.Size = uint64(len(data))
+			}
+		case elf.SectionFlag(s.Flags)&elf.SHF_ALLOC != 0:
+			return nil, fmt.Errorf("ELF section %s is loaded at runtime and too small (%d bytes, need %d)", name, s.Size, len(data))
+		default:
+			// don't leave the old content behind
+			for i := range old {
+				old[i] = 0
+			}
+			out, s.Offset = appendAligned(out, data, 8)
+			s.Size = uint64(len(data))
+		}
+		s.Flags &^= uint64(elf.SHF_COMPRESSED)
+		l.putSectionHeader(out[shoff+uint64(index*shentsize):], *s)
+		return checkSize(l, out)
+	}
+
+	// add the name to the section name table, which is moved to the end of
+	// the file, then add the data and a new section header table
+	strtab := &headers[shstrndx]
+	if strtab.Offset+strtab.Size > uint64(len(image)) {
+		return nil, errors.New("invalid ELF section name table")
+	}
+	names := append([]byte{}, image[strtab.Offset:strtab.Offset+strtab.Size]...)
+	nameOffset := uint32(len(names))
+	names = append(append(names, name...), 0)
+	out, strtab.Offset = appendAligned(out, names, 1)
+	strtab.Size = uint64(len(names))
+
+	s := sectionHeader{
+		Name:      nameOffset,
+		Type:      uint32(elf.SHT_PROGBITS),
+		Size:      uint64(len(data)),
+		Addralign: 1,
+	}
+	out, s.Offset = appendAligned(out, data, 8)
+	headers = append(headers, s)
+
+	table := make([]byte, len(headers)*shentsize)
+	for i, h := range headers {
+		l.putSectionHeader(table[i*shentsize:], h)
+	}
+	out, shoff = appendAligned(out, table, 8)
+	l.putSectionTable(out, shoff, len(headers))
+	return checkSize(l, out)
+}
+
+// checkSize makes sure that all offsets of 32 bit files still fit.
+func checkSize(l layout, image []byte) ([]byte, error) {
+	if !l.is64 && uint64(len(image)) > 0xffffffff {
+		return nil, errors.New("ELF32 file too large")
+	}
+	return image, nil
+}
@@ -0,0 +1,195 @@
+package elfsection
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+type fixtureSection struct {
+	name  string
+	typ   elf.SectionType
+	flags elf.SectionFlag
+	data  []byte
+}
+
+var fixtureSections = []fixtureSection{
+	{name: ".text", typ: elf.SHT_PROGBITS, flags: elf.SHF_ALLOC | elf.SHF_EXECINSTR, data: bytes.Repeat([]byte{0x90}, 64)},
+	{name: ".data", typ: elf.SHT_PROGBITS, flags: elf.SHF_ALLOC | elf.SHF_WRITE, data: []byte("some initialized data")},
+	{name: ".comment", typ: elf.SHT_PROGBITS, data: []byte("GCC: (GNU) 13.2.0\x00")},
+}
+
+// buildELF generates a relocatable ELF file of the given class and byte
+// order holding sections, followed by the section name table.
+func buildELF(t *testing.T, class elf.Class, order binary.ByteOrder, sections []fixtureSection) []byte {
+	t.Helper()
+	is64 := class == elf.ELFCLASS64
+	ehsize, shentsize := 52, 40
+	if is64 {
+		ehsize, shentsize = 64, 64
+	}
+	ident := [elf.EI_NIDENT]byte{0x7f, 'E', 'L', 'F', byte(class), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)}
+	if order == binary.BigEndian {
+		ident[elf.EI_DATA] = byte(elf.ELFDATA2MSB)
+	}
+
+	// data of all sections, the section name table comes last
+	shstrtab := []byte{0}
+	type placed struct {
+		fixtureSection
+		nameOffset uint32
+		offset     uint64
+	}
+	var all []placed
+	body := make([]byte, ehsize)
+	for _, s := range append(sections, fixtureSection{name: ".shstrtab", typ: elf.SHT_STRTAB}) {
+		p := placed{fixtureSection: s, nameOffset: uint32(len(shstrtab))}
+		shstrtab = append(append(shstrtab, s.name...), 0)
+		all = append(all, p)
+	}
+	all[len(all)-1].data = shstrtab
+	for i := range all {
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+		all[i].offset = uint64(len(body))
+		body = append(body, all[i].data...)
+	}
+	for len(body)%8 != 0 {
+		body = append(body, 0)
+	}
+	shoff := len(body)
+	shnum := len(all) + 1
+
+	var b bytes.Buffer
+	b.Write(body)
+	if is64 {
+		binary.Write(&b, order, elf.Section64{})
+		for _, s := range all {
+			binary.Write(&b, order, elf.Section64{Name: s.nameOffset, Type: uint32(s.typ), Flags: uint64(s.flags), Off: s.offset, Size: uint64(len(s.data)), Addralign: 1})
+		}
+	} else {
+		binary.Write(&b, order, elf.Section32{})
+		for _, s := range all {
+			binary.Write(&b, order, elf.Section32{Name: s.nameOffset, Type: uint32(s.typ), Flags: uint32(s.flags), Off: uint32(s.offset), Size: uint32(len(s.data)), Addralign: 1})
+		}
+	}
+	out := b.Bytes()
+
+	var header bytes.Buffer
+	if is64 {
+		binary.Write(&header, order, elf.Header64{Ident: ident, Type: uint16(elf.ET_REL), Machine: uint16(elf.EM_X86_64), Version: uint32(elf.EV_CURRENT),
+			Shoff: uint64(shoff), Ehsize: uint16(ehsize), Shentsize: uint16(shentsize), Shnum: uint16(shnum), Shstrndx: uint16(shnum - 1)})
+	} else {
+		binary.Write(&header, order, elf.Header32{Ident: ident, Type: uint16(elf.ET_REL), Machine: uint16(elf.EM_386), Version: uint32(elf.EV_CURRENT),
+			Shoff: uint32(shoff), Ehsize: uint16(ehsize), Shentsize: uint16(shentsize), Shnum: uint16(shnum), Shstrndx: uint16(shnum - 1)})
+	}
+	copy(out, header.Bytes())
+	return out
+}
+
+var fixtureKinds = []struct {
+	name  string
+	class elf.Class
+	order binary.ByteOrder
+}{
+	{"ELF64 little endian", elf.ELFCLASS64, binary.LittleEndian},
+	{"ELF64 big endian", elf.ELFCLASS64, binary.BigEndian},
+	{"ELF32 little endian", elf.ELFCLASS32, binary.LittleEndian},
+	{"ELF32 big endian", elf.ELFCLASS32, binary.BigEndian},
+}
+
+// checkELF makes sure debug/elf still parses image, that the section name
+// holds want and all other sections of the fixture are unchanged.
+func checkELF(t *testing.T, image []byte, name string, want []byte) {
+	t.Helper()
+	f, err := elf.NewFile(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("debug/elf can't parse the result: %v", err)
+	}
+	s := f.Section(name)
+	if s == nil {
+		t.Fatalf("debug/elf finds no section %s", name)
+	}
+	data, err := s.Data()
+	if err != nil {
+		t.Fatalf("debug/elf can't read section %s: %v", name, err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("debug/elf reads section %s as %q, want %q", name, data, want)
+	}
+	for _, fs := range fixtureSections {
+		if fs.name == name {
+			continue
+		}
+		s := f.Section(fs.name)
+		if s == nil {
+			t.Errorf("section %s is gone", fs.name)
+			continue
+		}
+		if data, err := s.Data(); err != nil || !bytes.Equal(data, fs.data) {
+			t.Errorf("section %s changed to %q (%v)", fs.name, data, err)
+		}
+	}
+}
+
+func TestSetRoundTrip(t *testing.T) {
+	first := bytes.Repeat([]byte("uSWID data "), 20)
+	smaller := []byte("smaller uSWID data")
+	larger := bytes.Repeat([]byte("larger uSWID data "), 40)
+	for _, kind := range fixtureKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			image := buildELF(t, kind.class, kind.order, fixtureSections)
+			orig := append([]byte{}, image...)
+			if !IsELF(bytes.NewReader(image)) {
+				t.Fatal("IsELF does not detect the fixture")
+			}
+			if _, err := Read(bytes.NewReader(image), DefaultName); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Read of missing section: %v, want %v", err, ErrNotFound)
+			}
+
+			// add a new section, then shrink it in place and move it to
+			// the end of the file
+			for _, data := range [][]byte{first, smaller, larger} {
+				out, err := Set(image, DefaultName, data)
+				if err != nil {
+					t.Fatalf("Set %d bytes: %v", len(data), err)
+				}
+				got, err := Read(bytes.NewReader(out), DefaultName)
+				if err != nil {
+					t.Fatalf("Read after Set: %v", err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("Read = %q, want %q", got, data)
+				}
+				checkELF(t, out, DefaultName, data)
+				image = out
+			}
+			if !bytes.Equal(orig, buildELF(t, kind.class, kind.order, fixtureSections)) {
+				t.Error("Set modified its input")
+			}
+		})
+	}
+}
+
+func TestSetAllocatedSection(t *testing.T) {
+	for _, kind := range fixtureKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			image := buildELF(t, kind.class, kind.order, fixtureSections)
+			// loaded sections keep their size, the rest is zeroed
+			out, err := Set(image, ".data", []byte("new"))
+			if err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			want := make([]byte, len(fixtureSections[1].data))
+			copy(want, "new")
+			checkELF(t, out, ".data", want)
+
+			if _, err := Set(image, ".text", make([]byte, 65)); err == nil {
+				t.Error("Set grew a section loaded at runtime")
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Package fmap implements a reader for the flash map (FMAP) used by coreboot
+// and ChromeOS to describe the layout of a flash image.
+package fmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Signature is the magic value every FMAP starts with.
+const Signature = "__FMAP__"
+
+const (
+	headerSize = 56
+	areaSize   = 42
+	nameLen    = 32
+)
+
+// area flags
+const (
+	AreaStatic     = 1 << 0
+	AreaCompressed = 1 << 1
+	AreaRO         = 1 << 2
+	AreaPreserve   = 1 << 3
+)
+
+// ErrNotFound is returned if the image does not contain a valid FMAP.
+var ErrNotFound = errors.New("could not find FMAP")
+
+// FMAP is a parsed flash map.
+type FMAP struct {
+	Offset   int64 // offset of the FMAP in the image
+	VerMajor uint8
+	VerMinor uint8
+	Base     uint64
+	Size     uint32
+	Name     string
+	Areas    []Area
+}
+
+// Area is a single region of the flash map. Offset is relative to the start
+// of the flash image.
+type Area struct {
+	Offset uint32
+	Size   uint32
+	Name   string
+	Flags  uint16
+}
+
+// End returns the offset of the first byte after the area.
+func (a Area) End() int64 {
+	return int64(a.Offset) + int64(a.Size)
+}
+
+// Contains reports whether offset lies within the area.
+func (a Area) Contains(offset int64) bool {
+	return offset >= int64(a.Offset) && offset < a.End()
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i != -1 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// parse decodes the FMAP at offset, it returns an error if it does not look
+// like a valid one.
+func parse(r io.ReaderAt, size int64, offset int64) (*FMAP, error) {
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, err
+	}
+	m := &FMAP{
+		Offset:   offset,
+		VerMajor: header[8],
+		VerMinor: header[9],
+		Base:     binary.LittleEndian.Uint64(header[10:18]),
+		Size:     binary.LittleEndian.Uint32(header[18:22]),
+		Name:     cString(header[22 : 22+nameLen]),
+	}
+	if m.VerMajor != 1 {
+		return nil, fmt.Errorf("unsupported FMAP version %d.%d", m.VerMajor, m.VerMinor)
+	}
+	nareas := int64(binary.LittleEndian.Uint16(header[54:56]))
+	if nareas*areaSize > size-offset-headerSize {
+		return nil, errors.New("FMAP areas exceed image")
+	}
+	areas := make([]byte, nareas*areaSize)
+	if _, err := r.ReadAt(areas, offset+headerSize); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(areas); i += areaSize {
+		a := areas[i : i+areaSize]
+		m.Areas = append(m.Areas, Area{
+			Offset: binary.LittleEndian.Uint32(a[0:4]),
+			Size:   binary.LittleEndian.Uint32(a[4:8]),
+			Name:   cString(a[8 : 8+nameLen]),
+			Flags:  binary.LittleEndian.Uint16(a[40:42]),
+		})
+	}
+	return m, nil
+}
+
+// Find returns the first valid FMAP in the first size bytes of r.
+func Find(r io.ReaderAt, size int64) (*FMAP, error) {
+	sig := []byte(Signature)
+	chunk := make([]byte, 1<<20)
+	for pos := int64(0); pos < size; {
+		n, err := r.ReadAt(chunk, pos)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n < len(sig) {
+			break
+		}
+		for i := 0; ; {
+			j := bytes.Index(chunk[i:n], sig)
+			if j == -1 {
+				break
+			}
+			offset := pos + int64(i+j)
+			i += j + 1
+			if size-offset < headerSize {
+				continue
+			}
+			// the signature might also show up in code referring to it
+			if m, err := parse(r, size, offset); err == nil {
+				return m, nil
+			}
+		}
+		// keep the tail, a signature might span two chunks
+		pos += int64(n - len(sig) + 1)
+	}
+	return nil, ErrNotFound
+}
+
+// Area returns the area called name or nil if there is none.
+func (m *FMAP) Area(name string) *Area {
+	for i := range m.Areas {
+		if m.Areas[i].Name == name {
+			return &m.Areas[i]
+		}
+	}
+	return nil
+}
+
+// AreaAt returns the innermost area containing offset or nil if there is
+// none. Areas nest, e.g. COREBOOT is part of RO_SECTION, which in turn is part
+// of WP_RO.
+func (m *FMAP) AreaAt(offset int64) *Area {
+	var found *Area
+	for i := range m.Areas {
+		a := &m.Areas[i]
+		if a.Contains(offset) && (found == nil || a.Size < found.Size) {
+			found = a
+		}
+	}
+	return found
+}
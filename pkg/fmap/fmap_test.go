@@ -0,0 +1,118 @@
+package fmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// buildFMAP encodes an FMAP version 1.1 describing a flash of size bytes.
+func buildFMAP(size uint32, areas []Area) []byte {
+	var b bytes.Buffer
+	name := make([]byte, nameLen)
+	copy(name, "FLASH")
+	b.WriteString(Signature)
+	b.Write([]byte{1, 1})
+	binary.Write(&b, binary.LittleEndian, uint64(0xff000000))
+	binary.Write(&b, binary.LittleEndian, size)
+	b.Write(name)
+	binary.Write(&b, binary.LittleEndian, uint16(len(areas)))
+	for _, a := range areas {
+		binary.Write(&b, binary.LittleEndian, a.Offset)
+		binary.Write(&b, binary.LittleEndian, a.Size)
+		name := make([]byte, nameLen)
+		copy(name, a.Name)
+		b.Write(name)
+		binary.Write(&b, binary.LittleEndian, a.Flags)
+	}
+	return b.Bytes()
+}
+
+var fixtureAreas = []Area{
+	{Offset: 0x0000, Size: 0x8000, Name: "WP_RO", Flags: AreaStatic | AreaRO},
+	{Offset: 0x0000, Size: 0x1000, Name: "FMAP", Flags: AreaStatic},
+	{Offset: 0x1000, Size: 0x7000, Name: "COREBOOT"},
+	{Offset: 0x8000, Size: 0x4000, Name: "FW_MAIN_A"},
+	{Offset: 0xc000, Size: 0x4000, Name: "FW_MAIN_B"},
+}
+
+// fixtureImage returns a flash image with the FMAP at offset 0x100. Some code
+// before it refers to the signature.
+func fixtureImage() []byte {
+	image := bytes.Repeat([]byte{0xff}, 0x10000)
+	copy(image[0x10:], Signature+"\x02\x00")
+	copy(image[0x100:], buildFMAP(uint32(len(image)), fixtureAreas))
+	return image
+}
+
+func TestFind(t *testing.T) {
+	image := fixtureImage()
+	m, err := Find(bytes.NewReader(image), int64(len(image)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Offset != 0x100 || m.VerMajor != 1 || m.VerMinor != 1 || m.Name != "FLASH" || m.Size != 0x10000 {
+		t.Errorf("FMAP = %+v", m)
+	}
+	if !reflect.DeepEqual(m.Areas, fixtureAreas) {
+		t.Errorf("areas = %+v, want %+v", m.Areas, fixtureAreas)
+	}
+
+	for _, test := range []struct {
+		offset int64
+		want   string
+	}{
+		{0x100, "FMAP"}, // FMAP is smaller than WP_RO
+		{0x1000, "COREBOOT"},
+		{0x7fff, "COREBOOT"},
+		{0x8000, "FW_MAIN_A"},
+		{0xbfff, "FW_MAIN_A"},
+		{0xc000, "FW_MAIN_B"},
+		{0x10000, ""},
+	} {
+		got := ""
+		if a := m.AreaAt(test.offset); a != nil {
+			got = a.Name
+		}
+		if got != test.want {
+			t.Errorf("AreaAt(%#x) = %q, want %q", test.offset, got, test.want)
+		}
+	}
+	if a := m.Area("FW_MAIN_B"); a == nil || a.Offset != 0xc000 || a.End() != 0x10000 {
+		t.Errorf("Area(FW_MAIN_B) = %+v", a)
+	}
+	if a := m.Area("RW_LEGACY"); a != nil {
+		t.Errorf("Area(RW_LEGACY) = %+v, want nil", a)
+	}
+}
+
+func TestFindAcrossChunks(t *testing.T) {
+	// the signature spans the boundary of the 1 MiB chunks Find reads
+	image := bytes.Repeat([]byte{0xff}, 2<<20)
+	offset := 1<<20 - 4
+	copy(image[offset:], buildFMAP(uint32(len(image)), fixtureAreas))
+	m, err := Find(bytes.NewReader(image), int64(len(image)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Offset != int64(offset) {
+		t.Errorf("found FMAP at %#x, want %#x", m.Offset, offset)
+	}
+}
+
+func TestFindInvalid(t *testing.T) {
+	fmap := buildFMAP(0x10000, fixtureAreas)
+	for name, image := range map[string][]byte{
+		"empty":           nil,
+		"no FMAP":         bytes.Repeat([]byte{0xff}, 0x1000),
+		"truncated":       fmap[:headerSize-1],
+		"truncated areas": fmap[:len(fmap)-1],
+		"version 2":       append(append([]byte(Signature), 2), fmap[len(Signature)+1:]...),
+	} {
+		if m, err := Find(bytes.NewReader(image), int64(len(image))); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: Find = %+v, %v, want %v", name, m, err, ErrNotFound)
+		}
+	}
+}
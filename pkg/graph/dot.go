@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dotQuote returns s as DOT string literal.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// WriteDOT renders g as Graphviz DOT digraph. Nodes are labeled with name,
+// version and vendor, edges with their rel and styled by it. Dangling link
+// targets are drawn as red dashed boxes, external targets as notes.
+func WriteDOT(w io.Writer, g *Graph) error {
+	var b strings.Builder
+	b.WriteString("digraph goswid {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, style=rounded, fontname=\"Helvetica\"];\n")
+	b.WriteString("\tedge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, n := range g.Nodes {
+		attrs := []string{"label=" + dotQuote(strings.Join(n.Label(), "\n"))}
+		switch n.Kind {
+		case External:
+			attrs = append(attrs, "shape=note", "style=solid", "color=dimgray")
+		case Dangling:
+			attrs = append(attrs, `style="rounded,dashed"`, "color=red", "fontcolor=red")
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", dotQuote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		s := styleOf(e.Rel)
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s, style=%s, color=%s, arrowhead=%s];\n",
+			dotQuote(e.From), dotQuote(e.To), dotQuote(e.Rel), s.style, s.color, s.arrowhead)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,230 @@
+// Package graph holds a relationship graph of software identities and renders
+// it in formats of standard graph tools.
+package graph
+
+import "fmt"
+
+// NodeKind tells what a node stands for.
+type NodeKind int
+
+const (
+	// Identity is a software identity of the graph.
+	Identity NodeKind = iota
+	// External is a link target outside of the tags, e.g. a license URL.
+	External
+	// Dangling is a tag a link points to, which is not part of the graph.
+	Dangling
+)
+
+func (k NodeKind) String() string {
+	switch k {
+	case Identity:
+		return "identity"
+	case External:
+		return "external"
+	case Dangling:
+		return "dangling"
+	}
+	return "unknown"
+}
+
+// Node is a vertex of the graph, IDs have to be unique.
+type Node struct {
+	ID      string
+	Kind    NodeKind
+	Name    string
+	Version string
+	Vendor  string
+	// Hidden is the number of links of the node left out by Limit.
+	Hidden int
+}
+
+// Label returns the name, version and vendor of the node on separate lines,
+// followed by notes on missing targets and hidden links.
+func (n Node) Label() []string {
+	name := n.Name
+	if name == "" {
+		name = n.ID
+	}
+	lines := []string{name}
+	if n.Version != "" {
+		lines = append(lines, n.Version)
+	}
+	if n.Vendor != "" {
+		lines = append(lines, n.Vendor)
+	}
+	if n.Kind == Dangling {
+		lines = append(lines, "(missing)")
+	}
+	if n.Hidden > 0 {
+		lines = append(lines, fmt.Sprintf("(+%d links)", n.Hidden))
+	}
+	return lines
+}
+
+// Edge is a directed link between the nodes with the IDs From and To, Rel
+// is the link relation, e.g. "requires" or "see-also".
+type Edge struct {
+	From string
+	To   string
+	Rel  string
+}
+
+// Graph is a directed graph, nodes and edges are rendered in order.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// Node returns the node with the ID id, or nil.
+func (g *Graph) Node(id string) *Node {
+	for i := range g.Nodes {
+		if g.Nodes[i].ID == id {
+			return &g.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// AddNode adds n unless the graph has a node with the same ID already.
+func (g *Graph) AddNode(n Node) {
+	if g.Node(n.ID) == nil {
+		g.Nodes = append(g.Nodes, n)
+	}
+}
+
+// AddEdge adds a link from the node from to the node to.
+func (g *Graph) AddEdge(from, to, rel string) {
+	g.Edges = append(g.Edges, Edge{From: from, To: to, Rel: rel})
+}
+
+// Roots returns the IDs of the nodes no other node links to, or the first
+// node if every node is linked to.
+func (g *Graph) Roots() []string {
+	linked := map[string]bool{}
+	for _, e := range g.Edges {
+		if e.From != e.To {
+			linked[e.To] = true
+		}
+	}
+	var roots []string
+	for _, n := range g.Nodes {
+		if !linked[n.ID] {
+			roots = append(roots, n.ID)
+		}
+	}
+	if len(roots) == 0 && len(g.Nodes) > 0 {
+		roots = []string{g.Nodes[0].ID}
+	}
+	return roots
+}
+
+// Limit returns the part of g which can be reached from the roots following
+// at most depth edges. Parts of the graph no root leads to (e.g. a cycle
+// beside the tree of the roots) are reached from their first node instead.
+// The nodes whose links were cut off count them in Hidden. A depth of 0 or
+// less returns g.
+func (g *Graph) Limit(depth int) *Graph {
+	if depth <= 0 {
+		return g
+	}
+	// every part of the graph needs a start, take the first node of those
+	// no root leads to
+	starts := g.Roots()
+	reached := map[string]bool{}
+	reach := func(queue []string) {
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if reached[id] {
+				continue
+			}
+			reached[id] = true
+			for _, e := range g.Edges {
+				if e.From == id {
+					queue = append(queue, e.To)
+				}
+			}
+		}
+	}
+	reach(starts)
+	for _, n := range g.Nodes {
+		if !reached[n.ID] {
+			starts = append(starts, n.ID)
+			reach([]string{n.ID})
+		}
+	}
+
+	level := map[string]int{}
+	queue := starts
+	for _, id := range queue {
+		level[id] = 0
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if level[id] == depth {
+			continue
+		}
+		for _, e := range g.Edges {
+			if _, seen := level[e.To]; e.From == id && !seen {
+				level[e.To] = level[id] + 1
+				queue = append(queue, e.To)
+			}
+		}
+	}
+
+	limited := &Graph{}
+	hidden := map[string]int{}
+	for _, e := range g.Edges {
+		from, ok := level[e.From]
+		if !ok {
+			continue
+		}
+		if _, ok := level[e.To]; ok && from < depth {
+			limited.Edges = append(limited.Edges, e)
+		} else {
+			hidden[e.From]++
+		}
+	}
+	for _, n := range g.Nodes {
+		if _, ok := level[n.ID]; ok {
+			n.Hidden += hidden[n.ID]
+			limited.Nodes = append(limited.Nodes, n)
+		}
+	}
+	return limited
+}
+
+// edgeStyle is how edges of a rel are drawn.
+type edgeStyle struct {
+	style     string
+	color     string
+	arrowhead string
+}
+
+var edgeStyles = map[string]edgeStyle{
+	"requires":     {"solid", "black", "normal"},
+	"compiler":     {"dashed", "blue", "normal"},
+	"license":      {"dotted", "darkgreen", "empty"},
+	"supplemental": {"solid", "purple", "odiamond"},
+	"patches":      {"bold", "red", "normal"},
+	"see-also":     {"dotted", "gray", "open"},
+}
+
+var defaultEdgeStyle = edgeStyle{"solid", "dimgray", "normal"}
+
+func styleOf(rel string) edgeStyle {
+	if s, ok := edgeStyles[rel]; ok {
+		return s
+	}
+	return defaultEdgeStyle
+}
+
+// Style returns the line style (solid, dashed, dotted or bold) and color
+// edges of rel are drawn with, so other renderers can match the writers of
+// this package.
+func Style(rel string) (line string, color string) {
+	s := styleOf(rel)
+	return s.style, s.color
+}
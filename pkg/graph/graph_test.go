@@ -0,0 +1,223 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// chain returns a graph linking the nodes with the IDs in order.
+func chain(ids ...string) *Graph {
+	g := &Graph{}
+	for i, id := range ids {
+		g.AddNode(Node{ID: id, Name: id})
+		if i > 0 {
+			g.AddEdge(ids[i-1], id, "requires")
+		}
+	}
+	return g
+}
+
+func nodeIDs(g *Graph) []string {
+	var ids []string
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
+
+func TestRoots(t *testing.T) {
+	g := chain("a", "b", "c")
+	g.AddNode(Node{ID: "d"})
+	g.AddEdge("d", "d", "see-also") // links to itself don't count
+	if got, want := g.Roots(), []string{"a", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Roots = %v, want %v", got, want)
+	}
+
+	cycle := chain("x", "y", "z")
+	cycle.AddEdge("z", "x", "requires")
+	if got, want := cycle.Roots(), []string{"x"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Roots of a cycle = %v, want %v", got, want)
+	}
+	if got := (&Graph{}).Roots(); len(got) != 0 {
+		t.Errorf("Roots of an empty graph = %v", got)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	g := chain("a", "b", "c", "d")
+	g.AddNode(Node{ID: "license", Kind: External})
+	g.AddEdge("a", "license", "license")
+	g.AddEdge("b", "license", "license")
+	g.AddEdge("c", "license", "license")
+
+	for _, test := range []struct {
+		depth  int
+		nodes  []string
+		edges  int
+		hidden map[string]int
+	}{
+		{0, []string{"a", "b", "c", "d", "license"}, 6, nil},
+		{1, []string{"a", "b", "license"}, 2, map[string]int{"b": 2}},
+		{2, []string{"a", "b", "c", "license"}, 4, map[string]int{"c": 2}},
+		{3, []string{"a", "b", "c", "d", "license"}, 6, nil},
+	} {
+		limited := g.Limit(test.depth)
+		if got := nodeIDs(limited); !reflect.DeepEqual(got, test.nodes) {
+			t.Errorf("depth %d: nodes = %v, want %v", test.depth, got, test.nodes)
+		}
+		if len(limited.Edges) != test.edges {
+			t.Errorf("depth %d: %d edges, want %d", test.depth, len(limited.Edges), test.edges)
+		}
+		for _, n := range limited.Nodes {
+			if n.Hidden != test.hidden[n.ID] {
+				t.Errorf("depth %d: node %s hides %d links, want %d", test.depth, n.ID, n.Hidden, test.hidden[n.ID])
+			}
+		}
+	}
+	if g.Node("b").Hidden != 0 {
+		t.Error("Limit modified the nodes of the graph")
+	}
+	if label := g.Limit(1).Node("b").Label(); label[len(label)-1] != "(+2 links)" {
+		t.Errorf("label of a cut off node = %q", label)
+	}
+}
+
+func TestLimitUnreachedCycle(t *testing.T) {
+	// a cycle beside the root has no root of its own
+	g := chain("root", "leaf")
+	g.AddNode(Node{ID: "x"})
+	g.AddNode(Node{ID: "y"})
+	g.AddNode(Node{ID: "z"})
+	g.AddEdge("x", "y", "requires")
+	g.AddEdge("y", "z", "requires")
+	g.AddEdge("z", "x", "requires")
+
+	limited := g.Limit(1)
+	if got, want := nodeIDs(limited), []string{"root", "leaf", "x", "y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("nodes = %v, want %v", got, want)
+	}
+	if y := limited.Node("y"); y == nil || y.Hidden != 1 {
+		t.Errorf("node y = %+v, want 1 hidden link", y)
+	}
+	if got := nodeIDs(g.Limit(5)); len(got) != 5 {
+		t.Errorf("nodes = %v, want all of them", got)
+	}
+}
+
+// hostileGraph has names and IDs with characters the formats have to escape.
+func hostileGraph() *Graph {
+	g := &Graph{}
+	g.AddNode(Node{ID: `a"b`, Name: `say "hi" & <bye>`, Version: "1.0\n2.0", Vendor: `C:\ACME`})
+	g.AddNode(Node{ID: "swid:gone", Kind: Dangling, Name: "gone"})
+	g.AddNode(Node{ID: "https://example.com/?a=1&b=<2>", Kind: External, Name: "https://example.com/?a=1&b=<2>"})
+	g.AddEdge(`a"b`, "swid:gone", "requires")
+	g.AddEdge(`a"b`, "https://example.com/?a=1&b=<2>", `li"cense`)
+	return g
+}
+
+func TestWriteDOT(t *testing.T) {
+	var b strings.Builder
+	if err := WriteDOT(&b, hostileGraph()); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`"a\"b" [label="say \"hi\" & <bye>\n1.0\n2.0\nC:\\ACME"];`,
+		`"swid:gone" [label="gone\n(missing)", style="rounded,dashed", color=red, fontcolor=red];`,
+		`"https://example.com/?a=1&b=<2>" [label="https://example.com/?a=1&b=<2>", shape=note`,
+		`"a\"b" -> "swid:gone" [label="requires", style=solid, color=black, arrowhead=normal];`,
+		`"a\"b" -> "https://example.com/?a=1&b=<2>" [label="li\"cense", style=solid, color=dimgray`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT lacks %s:\n%s", want, out)
+		}
+	}
+	// every line is complete, quotes pair up
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if n := strings.Count(line, `"`) - strings.Count(line, `\"`); n%2 != 0 {
+			t.Errorf("unbalanced quotes in %s", line)
+		}
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	g := hostileGraph()
+	var b bytes.Buffer
+	if err := WriteGraphML(&b, g); err != nil {
+		t.Fatal(err)
+	}
+	// the document has to be well-formed to be read back
+	d := xml.NewDecoder(bytes.NewReader(b.Bytes()))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("GraphML is not well-formed: %v\n%s", err, b.String())
+		}
+	}
+	var doc graphMLDocument
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.XMLName.Space != GraphMLNamespace || doc.Graph.EdgeDefault != "directed" {
+		t.Errorf("document = %+v", doc)
+	}
+	if len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 2 {
+		t.Fatalf("got %d nodes and %d edges, want 3 and 2", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+	first := doc.Graph.Nodes[0]
+	want := []graphMLData{{"name", `say "hi" & <bye>`}, {"version", "1.0\n2.0"}, {"vendor", `C:\ACME`}, {"kind", "identity"}}
+	if first.ID != `a"b` || !reflect.DeepEqual(first.Data, want) {
+		t.Errorf("node = %+v, want %+v", first, want)
+	}
+	if kind := doc.Graph.Nodes[1].Data[len(doc.Graph.Nodes[1].Data)-1]; kind != (graphMLData{"kind", "dangling"}) {
+		t.Errorf("dangling node has %+v", kind)
+	}
+	if e := doc.Graph.Edges[1]; e.Source != `a"b` || e.Target != g.Edges[1].To || e.Data[0].Value != `li"cense` {
+		t.Errorf("edge = %+v", e)
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	g := hostileGraph()
+	g.AddNode(Node{ID: "b", Name: "C# lib", Vendor: `say "hi" & <bye>`})
+	g.AddNode(Node{ID: "c", Name: "tool", Vendor: "ACME"})
+	g.Node(`a"b`).Vendor = "ACME"
+	g.AddEdge(`a"b`, "b", "requires")
+	g.AddEdge("c", "b", "compiler")
+
+	var b strings.Builder
+	if err := WriteMermaid(&b, g); err != nil {
+		t.Fatal(err)
+	}
+	want := `flowchart LR
+	subgraph v0["ACME"]
+		n0["say #quot;hi#quot; & #lt;bye#gt;<br/>1.0<br/>2.0"]
+		n4["tool"]
+	end
+	subgraph v1["say #quot;hi#quot; & #lt;bye#gt;"]
+		n3["C#35; lib"]
+	end
+	n1["gone<br/>(missing)"]
+	n2["https://example.com/?a=1&b=#lt;2#gt;"]
+	n0 -->|"requires"| n1
+	linkStyle 0 stroke:black
+	n0 -->|"li#quot;cense"| n2
+	linkStyle 1 stroke:dimgray
+	n0 -->|"requires"| n3
+	linkStyle 2 stroke:black
+	n4 -.->|"compiler"| n3
+	linkStyle 3 stroke:blue
+	classDef dangling stroke:red,stroke-dasharray:5 5,color:red
+	class n1 dangling
+	classDef external stroke:dimgray,fill:none
+	class n2 external
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteMermaid =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// GraphMLNamespace is the XML namespace of GraphML documents.
+const GraphMLNamespace = "http://graphml.graphdrawing.org/xmlns"
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// data returns the non-empty values as data elements.
+func data(values ...string) []graphMLData {
+	var d []graphMLData
+	for i := 0; i < len(values); i += 2 {
+		if values[i+1] != "" {
+			d = append(d, graphMLData{Key: values[i], Value: values[i+1]})
+		}
+	}
+	return d
+}
+
+// WriteGraphML renders g as GraphML document. Nodes carry their name,
+// version, vendor and kind (identity, external or dangling), edges their rel
+// as data attributes, so graph analysis tools can filter on them.
+func WriteGraphML(w io.Writer, g *Graph) error {
+	var doc graphMLDocument
+	doc.Keys = []graphMLKey{
+		{"name", "node", "name", "string"},
+		{"version", "node", "version", "string"},
+		{"vendor", "node", "vendor", "string"},
+		{"kind", "node", "kind", "string"},
+		{"rel", "edge", "rel", "string"},
+	}
+	doc.Graph.ID = "goswid"
+	doc.Graph.EdgeDefault = "directed"
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID:   n.ID,
+			Data: data("name", n.Name, "version", n.Version, "vendor", n.Vendor, "kind", n.Kind.String()),
+		})
+	}
+	for i, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: e.From,
+			Target: e.To,
+			Data:   data("rel", e.Rel),
+		})
+	}
+	buf, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, xml.Header+string(buf)+"\n")
+	return err
+}
@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mermaidText escapes s for a quoted Mermaid label. # starts the entity
+// codes, so it needs to be escaped as well, line breaks would end the
+// statement.
+func mermaidText(s string) string {
+	return strings.NewReplacer("#", "#35;", `"`, "#quot;", "<", "#lt;", ">", "#gt;", "\r", "", "\n", "<br/>").Replace(s)
+}
+
+// mermaidArrows are the Mermaid links for the edge styles.
+var mermaidArrows = map[string]string{
+	"solid":  "-->",
+	"dashed": "-.->",
+	"dotted": "-.->",
+	"bold":   "==>",
+}
+
+// WriteMermaid renders g as Mermaid flowchart. Identities are grouped in a
+// subgraph per vendor, edges are labeled with their rel and styled like in
+// WriteDOT. Dangling link targets get the class dangling.
+func WriteMermaid(w io.Writer, g *Graph) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	ids := map[string]string{}
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+	node := func(indent string, n Node) {
+		// the vendor is the title of the subgraph
+		n.Vendor = ""
+		lines := n.Label()
+		for i := range lines {
+			lines[i] = mermaidText(lines[i])
+		}
+		fmt.Fprintf(&b, "%s%s[\"%s\"]\n", indent, ids[n.ID], strings.Join(lines, "<br/>"))
+	}
+
+	var vendors []string
+	byVendor := map[string][]Node{}
+	for _, n := range g.Nodes {
+		if n.Kind == Identity && n.Vendor != "" {
+			if _, ok := byVendor[n.Vendor]; !ok {
+				vendors = append(vendors, n.Vendor)
+			}
+			byVendor[n.Vendor] = append(byVendor[n.Vendor], n)
+		}
+	}
+	for i, vendor := range vendors {
+		fmt.Fprintf(&b, "\tsubgraph v%d[\"%s\"]\n", i, mermaidText(vendor))
+		for _, n := range byVendor[vendor] {
+			node("\t\t", n)
+		}
+		b.WriteString("\tend\n")
+	}
+	var dangling, external []string
+	for _, n := range g.Nodes {
+		if n.Kind == Identity && n.Vendor != "" {
+			continue
+		}
+		node("\t", n)
+		switch n.Kind {
+		case Dangling:
+			dangling = append(dangling, ids[n.ID])
+		case External:
+			external = append(external, ids[n.ID])
+		}
+	}
+
+	for i, e := range g.Edges {
+		s := styleOf(e.Rel)
+		fmt.Fprintf(&b, "\t%s %s|\"%s\"| %s\n", ids[e.From], mermaidArrows[s.style], mermaidText(e.Rel), ids[e.To])
+		fmt.Fprintf(&b, "\tlinkStyle %d stroke:%s\n", i, s.color)
+	}
+
+	if len(dangling) > 0 {
+		b.WriteString("\tclassDef dangling stroke:red,stroke-dasharray:5 5,color:red\n")
+		fmt.Fprintf(&b, "\tclass %s dangling\n", strings.Join(dangling, ","))
+	}
+	if len(external) > 0 {
+		b.WriteString("\tclassDef external stroke:dimgray,fill:none\n")
+		fmt.Fprintf(&b, "\tclass %s external\n", strings.Join(external, ","))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
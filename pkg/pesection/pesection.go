@@ -0,0 +1,218 @@
+// Package pesection reads and appends single sections of PE/COFF images, like
+// the .sbom section holding the uSWID data of an EFI application or driver.
+package pesection
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultName is the name of the section holding the SBOM.
+const DefaultName = ".sbom"
+
+// ErrNotFound is returned if the PE image has no section of the given name.
+var ErrNotFound = errors.New("PE section not found")
+
+const (
+	coffHeaderSize    = 20
+	sectionHeaderSize = 40
+
+	// offsets in the optional header, the same for PE32 and PE32+
+	optSizeOfInitializedData = 8
+	optSectionAlignment      = 32
+	optFileAlignment         = 36
+	optSizeOfImage           = 56
+	optSizeOfHeaders         = 60
+	optCheckSum              = 64
+
+	// IMAGE_SCN_CNT_INITIALIZED_DATA | IMAGE_SCN_MEM_READ
+	sectionCharacteristics = 0x40000040
+)
+
+// peHeaderOffset returns the offset of the PE signature.
+func peHeaderOffset(r io.ReaderAt) (int64, error) {
+	dos := make([]byte, 0x40)
+	if _, err := r.ReadAt(dos, 0); err != nil {
+		return 0, err
+	}
+	if dos[0] != 'M' || dos[1] != 'Z' {
+		return 0, errors.New("missing DOS header")
+	}
+	offset := int64(binary.LittleEndian.Uint32(dos[0x3c:]))
+	sig := make([]byte, 4)
+	if _, err := r.ReadAt(sig, offset); err != nil {
+		return 0, err
+	}
+	if string(sig) != "PE\x00\x00" {
+		return 0, errors.New("missing PE signature")
+	}
+	return offset, nil
+}
+
+// IsPE reports whether r starts with a DOS header pointing to a PE header.
+func IsPE(r io.ReaderAt) bool {
+	_, err := peHeaderOffset(r)
+	return err == nil
+}
+
+// Read returns the content of the section called name.
+func Read(r io.ReaderAt, name string) ([]byte, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	s := f.Section(name)
+	if s == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	data, err := s.Data()
+	if err != nil {
+		return nil, fmt.Errorf("reading PE section %s: %w", name, err)
+	}
+	// the raw data is padded to the file alignment
+	if s.VirtualSize != 0 && s.VirtualSize < uint32(len(data)) {
+		data = data[:s.VirtualSize]
+	}
+	return data, nil
+}
+
+func alignUp(n, a uint32) uint32 {
+	if a == 0 {
+		return n
+	}
+	return (n + a - 1) / a * a
+}
+
+// Append adds a new initialized, read-only data section called name holding
+// data to the PE image and returns the new image. The section table, the size
+// of the image and the checksum (if set) are updated. There must be room for
+// another section header before the first section, signed images are refused
+// as appending would break the signature. The input image is not modified.
+func Append(image []byte, name string, data []byte) ([]byte, error) {
+	if len(name) > 8 {
+		return nil, fmt.Errorf("PE section name %q longer than 8 bytes", name)
+	}
+	f, err := pe.NewFile(bytes.NewReader(image))
+	if err != nil {
+		return nil, err
+	}
+	if f.Section(name) != nil {
+		return nil, fmt.Errorf("PE image already has a section %s", name)
+	}
+	peOffset, err := peHeaderOffset(bytes.NewReader(image))
+	if err != nil {
+		return nil, err
+	}
+	coff := image[peOffset+4:]
+	numSections := binary.LittleEndian.Uint16(coff[2:])
+	optSize := int64(binary.LittleEndian.Uint16(coff[16:]))
+	optOffset := peOffset + 4 + coffHeaderSize
+	if optSize < optCheckSum+4 || optOffset+optSize > int64(len(image)) {
+		return nil, errors.New("PE image without optional header")
+	}
+
+	var certSize uint32
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+			certSize = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY].Size
+		}
+	case *pe.OptionalHeader64:
+		if oh.NumberOfRvaAndSizes > pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+			certSize = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY].Size
+		}
+	}
+	if certSize != 0 {
+		return nil, errors.New("PE image is signed, append the section before signing it")
+	}
+
+	out := append([]byte{}, image...)
+	opt := out[optOffset : optOffset+optSize]
+	sectionAlignment := binary.LittleEndian.Uint32(opt[optSectionAlignment:])
+	fileAlignment := binary.LittleEndian.Uint32(opt[optFileAlignment:])
+	sizeOfHeaders := int64(binary.LittleEndian.Uint32(opt[optSizeOfHeaders:]))
+
+	// the new header has to fit in between the section table and the data
+	// of the first section
+	tableEnd := optOffset + optSize + int64(numSections)*sectionHeaderSize
+	room := sizeOfHeaders
+	var virtualEnd uint32
+	for _, s := range f.Sections {
+		if s.Offset != 0 && int64(s.Offset) < room {
+			room = int64(s.Offset)
+		}
+		size := s.VirtualSize
+		if s.Size > size {
+			size = s.Size
+		}
+		if end := s.VirtualAddress + size; end > virtualEnd {
+			virtualEnd = end
+		}
+	}
+	if tableEnd+sectionHeaderSize > room || tableEnd+sectionHeaderSize > int64(len(out)) {
+		return nil, errors.New("no room for another PE section header")
+	}
+	for _, b := range out[tableEnd : tableEnd+sectionHeaderSize] {
+		if b != 0 {
+			return nil, errors.New("no room for another PE section header")
+		}
+	}
+
+	// the data goes to the end of the file, after all sections and whatever
+	// else is appended to the image
+	rawOffset := alignUp(uint32(len(out)), fileAlignment)
+	rawSize := alignUp(uint32(len(data)), fileAlignment)
+	virtualAddress := alignUp(virtualEnd, sectionAlignment)
+	if uint64(rawOffset)+uint64(rawSize) > 0xffffffff || uint64(virtualAddress)+uint64(len(data)) > 0xffffffff {
+		return nil, errors.New("PE image too large")
+	}
+	for uint32(len(out)) < rawOffset {
+		out = append(out, 0)
+	}
+	out = append(out, data...)
+	for uint32(len(out)) < rawOffset+rawSize {
+		out = append(out, 0)
+	}
+
+	h := out[tableEnd : tableEnd+sectionHeaderSize]
+	copy(h[0:8], name)
+	binary.LittleEndian.PutUint32(h[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(h[12:], virtualAddress)
+	binary.LittleEndian.PutUint32(h[16:], rawSize)
+	binary.LittleEndian.PutUint32(h[20:], rawOffset)
+	binary.LittleEndian.PutUint32(h[36:], sectionCharacteristics)
+
+	coff = out[peOffset+4:]
+	binary.LittleEndian.PutUint16(coff[2:], numSections+1)
+	opt = out[optOffset : optOffset+optSize]
+	initialized := binary.LittleEndian.Uint32(opt[optSizeOfInitializedData:])
+	binary.LittleEndian.PutUint32(opt[optSizeOfInitializedData:], initialized+rawSize)
+	binary.LittleEndian.PutUint32(opt[optSizeOfImage:], alignUp(virtualAddress+uint32(len(data)), sectionAlignment))
+	if binary.LittleEndian.Uint32(opt[optCheckSum:]) != 0 {
+		binary.LittleEndian.PutUint32(opt[optCheckSum:], checksum(out, optOffset+optCheckSum))
+	}
+	return out, nil
+}
+
+// checksum computes the PE image checksum, skipping the checksum field at
+// offset.
+func checksum(image []byte, offset int64) uint32 {
+	var sum uint64
+	for i := int64(0); i < int64(len(image)); i += 2 {
+		if i == offset || i == offset+2 {
+			continue
+		}
+		word := uint64(image[i])
+		if i+1 < int64(len(image)) {
+			word |= uint64(image[i+1]) << 8
+		}
+		sum += word
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	sum = (sum & 0xffff) + (sum >> 16)
+	return uint32(sum) + uint32(len(image))
+}
@@ -0,0 +1,234 @@
+package pesection
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+type fixtureSection struct {
+	name string
+	data []byte
+}
+
+var fixtureSections = []fixtureSection{
+	{name: ".text", data: bytes.Repeat([]byte{0x90}, 64)},
+	{name: ".data", data: []byte("some initialized data")},
+}
+
+const (
+	fixtureFileAlignment    = 0x200
+	fixtureSectionAlignment = 0x1000
+)
+
+type fixtureOptions struct {
+	sizeOfHeaders uint32 // 0x200 if zero
+	checksum      bool
+	certSize      uint32
+}
+
+// buildPE generates a PE32 or PE32+ image holding sections.
+func buildPE(t *testing.T, is64 bool, sections []fixtureSection, opts fixtureOptions) []byte {
+	t.Helper()
+	if opts.sizeOfHeaders == 0 {
+		opts.sizeOfHeaders = fixtureFileAlignment
+	}
+	var headers []pe.SectionHeader32
+	raw := alignUp(opts.sizeOfHeaders, fixtureFileAlignment)
+	virtual := uint32(fixtureSectionAlignment)
+	for _, s := range sections {
+		h := pe.SectionHeader32{
+			VirtualSize:      uint32(len(s.data)),
+			VirtualAddress:   virtual,
+			SizeOfRawData:    alignUp(uint32(len(s.data)), fixtureFileAlignment),
+			PointerToRawData: raw,
+			Characteristics:  sectionCharacteristics,
+		}
+		copy(h.Name[:], s.name)
+		headers = append(headers, h)
+		raw += h.SizeOfRawData
+		virtual += alignUp(uint32(len(s.data)), fixtureSectionAlignment)
+	}
+	var dirs [16]pe.DataDirectory
+	dirs[pe.IMAGE_DIRECTORY_ENTRY_SECURITY] = pe.DataDirectory{VirtualAddress: raw, Size: opts.certSize}
+
+	var opt bytes.Buffer
+	if is64 {
+		binary.Write(&opt, binary.LittleEndian, pe.OptionalHeader64{Magic: 0x20b, SectionAlignment: fixtureSectionAlignment, FileAlignment: fixtureFileAlignment,
+			SizeOfImage: virtual, SizeOfHeaders: opts.sizeOfHeaders, Subsystem: pe.IMAGE_SUBSYSTEM_EFI_APPLICATION, NumberOfRvaAndSizes: 16, DataDirectory: dirs})
+	} else {
+		binary.Write(&opt, binary.LittleEndian, pe.OptionalHeader32{Magic: 0x10b, SectionAlignment: fixtureSectionAlignment, FileAlignment: fixtureFileAlignment,
+			SizeOfImage: virtual, SizeOfHeaders: opts.sizeOfHeaders, Subsystem: pe.IMAGE_SUBSYSTEM_EFI_APPLICATION, NumberOfRvaAndSizes: 16, DataDirectory: dirs})
+	}
+	machine := uint16(pe.IMAGE_FILE_MACHINE_I386)
+	if is64 {
+		machine = pe.IMAGE_FILE_MACHINE_AMD64
+	}
+
+	var b bytes.Buffer
+	dos := make([]byte, 0x40)
+	dos[0], dos[1] = 'M', 'Z'
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	b.Write(dos)
+	b.WriteString("PE\x00\x00")
+	binary.Write(&b, binary.LittleEndian, pe.FileHeader{Machine: machine, NumberOfSections: uint16(len(sections)),
+		SizeOfOptionalHeader: uint16(opt.Len()), Characteristics: pe.IMAGE_FILE_EXECUTABLE_IMAGE})
+	b.Write(opt.Bytes())
+	binary.Write(&b, binary.LittleEndian, headers)
+	if b.Len() > int(opts.sizeOfHeaders) {
+		t.Fatalf("headers of %d bytes exceed SizeOfHeaders %#x", b.Len(), opts.sizeOfHeaders)
+	}
+	image := b.Bytes()
+	for i, s := range sections {
+		for len(image) < int(headers[i].PointerToRawData) {
+			image = append(image, 0)
+		}
+		image = append(image, s.data...)
+	}
+	for len(image) < int(raw) {
+		image = append(image, 0)
+	}
+	if opts.certSize != 0 {
+		image = append(image, make([]byte, opts.certSize)...)
+	}
+	if opts.checksum {
+		offset := int64(0x40 + 4 + coffHeaderSize + optCheckSum)
+		binary.LittleEndian.PutUint32(image[offset:], checksum(image, offset))
+	}
+	return image
+}
+
+var fixtureKinds = []struct {
+	name string
+	is64 bool
+}{
+	{"PE32+", true},
+	{"PE32", false},
+}
+
+// checkPE makes sure debug/pe still parses image, that the section name
+// holds want and all other sections of the fixture are unchanged.
+func checkPE(t *testing.T, image []byte, name string, want []byte) {
+	t.Helper()
+	f, err := pe.NewFile(bytes.NewReader(image))
+	if err != nil {
+		t.Fatalf("debug/pe can't parse the result: %v", err)
+	}
+	s := f.Section(name)
+	if s == nil {
+		t.Fatalf("debug/pe finds no section %s", name)
+	}
+	if got, err := Read(bytes.NewReader(image), name); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("Read of section %s = %q, %v, want %q", name, got, err, want)
+	}
+	if s.VirtualAddress%fixtureSectionAlignment != 0 || s.Offset%fixtureFileAlignment != 0 {
+		t.Errorf("section %s at unaligned address %#x, offset %#x", name, s.VirtualAddress, s.Offset)
+	}
+	for _, fs := range fixtureSections {
+		data, err := Read(bytes.NewReader(image), fs.name)
+		if err != nil || !bytes.Equal(data, fs.data) {
+			t.Errorf("section %s changed to %q (%v)", fs.name, data, err)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	data := []byte("uSWID data")
+	for _, kind := range fixtureKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			image := buildPE(t, kind.is64, fixtureSections, fixtureOptions{checksum: true})
+			orig := append([]byte{}, image...)
+			if !IsPE(bytes.NewReader(image)) {
+				t.Fatal("IsPE does not detect the fixture")
+			}
+			if _, err := Read(bytes.NewReader(image), DefaultName); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Read of missing section: %v, want %v", err, ErrNotFound)
+			}
+
+			out, err := Append(image, DefaultName, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkPE(t, out, DefaultName, data)
+			if !bytes.Equal(image, orig) {
+				t.Error("Append modified its input")
+			}
+
+			f, err := pe.NewFile(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sizeOfImage, sum uint32
+			switch oh := f.OptionalHeader.(type) {
+			case *pe.OptionalHeader32:
+				sizeOfImage, sum = oh.SizeOfImage, oh.CheckSum
+			case *pe.OptionalHeader64:
+				sizeOfImage, sum = oh.SizeOfImage, oh.CheckSum
+			}
+			s := f.Section(DefaultName)
+			if sizeOfImage != s.VirtualAddress+fixtureSectionAlignment {
+				t.Errorf("SizeOfImage = %#x, want %#x", sizeOfImage, s.VirtualAddress+fixtureSectionAlignment)
+			}
+			if want := checksum(out, 0x40+4+coffHeaderSize+optCheckSum); sum != want {
+				t.Errorf("CheckSum = %#x, want %#x", sum, want)
+			}
+
+			// appending twice is refused
+			if _, err := Append(out, DefaultName, data); err == nil {
+				t.Error("Append of a duplicate section name succeeded")
+			}
+		})
+	}
+}
+
+func TestAppendRefused(t *testing.T) {
+	for _, kind := range fixtureKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			image := buildPE(t, kind.is64, fixtureSections, fixtureOptions{})
+			if _, err := Append(image, ".text", nil); err == nil {
+				t.Error("Append of an existing section name succeeded")
+			}
+			if _, err := Append(image, ".too_long", nil); err == nil {
+				t.Error("Append of a 9 byte name succeeded")
+			}
+
+			signed := buildPE(t, kind.is64, fixtureSections, fixtureOptions{certSize: 0x100})
+			if _, err := Append(signed, DefaultName, []byte("data")); err == nil {
+				t.Error("Append to a signed image succeeded")
+			}
+
+			// the section table ends where the headers end
+			optSize := 224
+			if kind.is64 {
+				optSize = 240
+			}
+			tableEnd := uint32(0x40 + 4 + coffHeaderSize + optSize + len(fixtureSections)*sectionHeaderSize)
+			full := buildPE(t, kind.is64, fixtureSections, fixtureOptions{sizeOfHeaders: tableEnd})
+			if _, err := Append(full, DefaultName, []byte("data")); err == nil {
+				t.Error("Append with a full section table succeeded")
+			}
+			// something else lives behind the section table
+			used := append([]byte{}, image...)
+			used[tableEnd+8] = 1
+			if _, err := Append(used, DefaultName, []byte("data")); err == nil {
+				t.Error("Append overwrote data behind the section table")
+			}
+		})
+	}
+}
+
+func TestReadNotPE(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":        nil,
+		"no PE header": append([]byte("MZ"), make([]byte, 0x100)...),
+	} {
+		if IsPE(bytes.NewReader(data)) {
+			t.Errorf("%s: IsPE", name)
+		}
+		if _, err := Read(bytes.NewReader(data), DefaultName); err == nil {
+			t.Errorf("%s: Read succeeded", name)
+		}
+	}
+}
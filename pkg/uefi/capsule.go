@@ -0,0 +1,139 @@
+package uefi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotCapsule is returned by ParseCapsule if the data does not start with a
+// known capsule header.
+var ErrNotCapsule = errors.New("no UEFI capsule")
+
+// capsule GUIDs
+var (
+	// FMPCapsuleGUID marks capsules for the firmware management protocol,
+	// which contain one or more firmware images.
+	FMPCapsuleGUID = mustParseGUID("6DCBD5ED-E82D-4C44-BDA1-7194199AD92A")
+	// CapsuleGUID marks plain capsules, which contain a single image.
+	CapsuleGUID = mustParseGUID("3B6686BD-0D76-4030-B70E-B5519E2FC5A0")
+)
+
+const (
+	capsuleHeaderSize    = 28
+	fmpHeaderSize        = 8
+	fmpImageHeaderSizeV1 = 32
+	fmpImageHeaderSizeV2 = 40
+	fmpImageHeaderSizeV3 = 48
+)
+
+// Capsule is a parsed UEFI capsule header (EFI_CAPSULE_HEADER).
+type Capsule struct {
+	GUID       GUID
+	HeaderSize uint32
+	Flags      uint32
+	ImageSize  uint32 // size of the whole capsule including the header
+	Images     []CapsuleImage
+}
+
+// CapsuleImage is a firmware image carried by a capsule. Plain capsules carry
+// a single image without type.
+type CapsuleImage struct {
+	TypeID GUID  // UpdateImageTypeId of FMP capsules
+	Index  uint8 // UpdateImageIndex of FMP capsules
+	Offset int64 // offset of the image in the capsule
+	Size   int64
+}
+
+// ParseCapsule parses the capsule header at the start of the first size bytes
+// of r and returns where the firmware images are. ErrNotCapsule is returned
+// if there is no capsule header.
+func ParseCapsule(r io.ReaderAt, size int64) (*Capsule, error) {
+	header := make([]byte, capsuleHeaderSize)
+	if size < capsuleHeaderSize {
+		return nil, ErrNotCapsule
+	}
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	c := &Capsule{
+		GUID:       readGUID(header[0:16]),
+		HeaderSize: binary.LittleEndian.Uint32(header[16:20]),
+		Flags:      binary.LittleEndian.Uint32(header[20:24]),
+		ImageSize:  binary.LittleEndian.Uint32(header[24:28]),
+	}
+	if c.GUID != FMPCapsuleGUID && c.GUID != CapsuleGUID {
+		return nil, ErrNotCapsule
+	}
+	if c.HeaderSize < capsuleHeaderSize || c.HeaderSize > c.ImageSize || int64(c.ImageSize) > size {
+		return nil, fmt.Errorf("invalid capsule header sizes (header %d bytes, capsule %d bytes, file %d bytes)", c.HeaderSize, c.ImageSize, size)
+	}
+	body := io.NewSectionReader(r, int64(c.HeaderSize), int64(c.ImageSize-c.HeaderSize))
+	if c.GUID != FMPCapsuleGUID {
+		c.Images = []CapsuleImage{{Offset: int64(c.HeaderSize), Size: body.Size()}}
+		return c, nil
+	}
+	images, err := parseFMPCapsule(body)
+	if err != nil {
+		return nil, fmt.Errorf("FMP capsule: %w", err)
+	}
+	for i := range images {
+		images[i].Offset += int64(c.HeaderSize)
+	}
+	c.Images = images
+	return c, nil
+}
+
+// parseFMPCapsule parses the EFI_FIRMWARE_MANAGEMENT_CAPSULE_HEADER at the
+// start of r and returns the payload items. Embedded drivers are skipped.
+func parseFMPCapsule(r *io.SectionReader) ([]CapsuleImage, error) {
+	header := make([]byte, fmpHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	drivers := int(binary.LittleEndian.Uint16(header[4:6]))
+	payloads := int(binary.LittleEndian.Uint16(header[6:8]))
+	offsets := make([]byte, 8*(drivers+payloads))
+	if _, err := r.ReadAt(offsets, fmpHeaderSize); err != nil {
+		return nil, fmt.Errorf("reading item offsets: %w", err)
+	}
+
+	var images []CapsuleImage
+	for i := drivers; i < drivers+payloads; i++ {
+		offset := int64(binary.LittleEndian.Uint64(offsets[8*i:]))
+		item := make([]byte, fmpImageHeaderSizeV3)
+		if offset < 0 || offset > r.Size() {
+			return nil, fmt.Errorf("item %d outside of capsule", i)
+		}
+		n, err := r.ReadAt(item, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		var headerSize int
+		switch version := binary.LittleEndian.Uint32(item[0:4]); {
+		case version == 1:
+			headerSize = fmpImageHeaderSizeV1
+		case version == 2:
+			headerSize = fmpImageHeaderSizeV2
+		case version >= 3:
+			headerSize = fmpImageHeaderSizeV3
+		default:
+			return nil, fmt.Errorf("item %d has unknown version %d", i, version)
+		}
+		if n < headerSize {
+			return nil, fmt.Errorf("item %d: %w", i, io.ErrUnexpectedEOF)
+		}
+		img := CapsuleImage{
+			TypeID: readGUID(item[4:20]),
+			Index:  item[20],
+			Offset: offset + int64(headerSize),
+			Size:   int64(binary.LittleEndian.Uint32(item[24:28])),
+		}
+		if img.Size > r.Size()-img.Offset {
+			return nil, fmt.Errorf("item %d exceeds capsule", i)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
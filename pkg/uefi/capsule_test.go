@@ -0,0 +1,91 @@
+package uefi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+var fmpImageType = mustParseGUID("DEADBEEF-0000-1111-2222-333344445555")
+
+// buildCapsule wraps body into a capsule header of the given GUID.
+func buildCapsule(guid GUID, body []byte) []byte {
+	c := make([]byte, capsuleHeaderSize+4) // headers may be larger
+	copy(c, guid[:])
+	binary.LittleEndian.PutUint32(c[16:], uint32(len(c)))
+	binary.LittleEndian.PutUint32(c[24:], uint32(len(c)+len(body)))
+	return append(c, body...)
+}
+
+// buildFMPCapsule returns an FMP capsule with an embedded driver and a
+// version 3 payload item holding image.
+func buildFMPCapsule(image []byte) []byte {
+	body := make([]byte, fmpHeaderSize+16)
+	binary.LittleEndian.PutUint32(body[0:], 1)
+	binary.LittleEndian.PutUint16(body[4:], 1)
+	binary.LittleEndian.PutUint16(body[6:], 1)
+	driver := []byte("MZ driver")
+	binary.LittleEndian.PutUint64(body[8:], uint64(len(body)))
+	binary.LittleEndian.PutUint64(body[16:], uint64(len(body)+len(driver)))
+	body = append(body, driver...)
+
+	item := make([]byte, fmpImageHeaderSizeV3)
+	binary.LittleEndian.PutUint32(item[0:], 3)
+	copy(item[4:], fmpImageType[:])
+	item[20] = 1
+	binary.LittleEndian.PutUint32(item[24:], uint32(len(image)))
+	body = append(append(body, item...), image...)
+	return buildCapsule(FMPCapsuleGUID, body)
+}
+
+func TestParseCapsule(t *testing.T) {
+	fv := fixtureVolume(t)
+	for _, test := range []struct {
+		name    string
+		capsule []byte
+		typeID  GUID
+		index   uint8
+	}{
+		{"plain", buildCapsule(CapsuleGUID, fv), GUID{}, 0},
+		{"FMP", buildFMPCapsule(fv), fmpImageType, 1},
+	} {
+		c, err := ParseCapsule(bytes.NewReader(test.capsule), int64(len(test.capsule)))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(c.Images) != 1 {
+			t.Fatalf("%s: images = %+v", test.name, c.Images)
+		}
+		img := c.Images[0]
+		if img.TypeID != test.typeID || img.Index != test.index {
+			t.Errorf("%s: image type %s index %d, want %s %d", test.name, img.TypeID, img.Index, test.typeID, test.index)
+		}
+		if img.Size != int64(len(fv)) || !bytes.Equal(test.capsule[img.Offset:img.Offset+img.Size], fv) {
+			t.Errorf("%s: image at %#x of %d bytes is not the volume", test.name, img.Offset, img.Size)
+		}
+	}
+}
+
+func TestParseCapsuleInvalid(t *testing.T) {
+	fv := fixtureVolume(t)
+	if _, err := ParseCapsule(bytes.NewReader(fv), int64(len(fv))); !errors.Is(err, ErrNotCapsule) {
+		t.Errorf("ParseCapsule of a volume: %v, want %v", err, ErrNotCapsule)
+	}
+	if _, err := ParseCapsule(bytes.NewReader(nil), 0); !errors.Is(err, ErrNotCapsule) {
+		t.Errorf("ParseCapsule of nothing: %v, want %v", err, ErrNotCapsule)
+	}
+
+	capsule := buildFMPCapsule(fv)
+	badVersion := append([]byte{}, capsule...)
+	badVersion[capsuleHeaderSize+4+fmpHeaderSize+16+len("MZ driver")] = 0
+	for name, data := range map[string][]byte{
+		"truncated":       capsule[:len(capsule)-1],
+		"item version 0":  badVersion,
+		"image too large": buildCapsule(FMPCapsuleGUID, capsule[capsuleHeaderSize+4:len(capsule)-1]),
+	} {
+		if _, err := ParseCapsule(bytes.NewReader(data), int64(len(data))); err == nil || errors.Is(err, ErrNotCapsule) {
+			t.Errorf("%s: ParseCapsule = %v, want a parse error", name, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package uefi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// GUIDs of the GUID-defined sections EDK2 creates
+var (
+	LZMACustomDecompressGUID    = mustParseGUID("EE4E5898-3914-4259-9D6E-DC7BD79403CF")
+	LZMAF86CustomDecompressGUID = mustParseGUID("D42AE6BD-1352-4BFB-909A-CA72A6EAE889")
+	CRC32GUID                   = mustParseGUID("FC1BCDB0-7D31-49AA-936A-A4600D9DD083")
+	TianoCustomDecompressGUID   = mustParseGUID("A31280AD-481E-41B6-95E8-127F4C984779")
+	BrotliCustomDecompressGUID  = mustParseGUID("3D532050-5CDA-4FD0-879E-0F7F630D5AFB")
+)
+
+// EFI_GUIDED_SECTION_PROCESSING_REQUIRED
+const guidedSectionProcessingRequired = 0x01
+
+// largest dictionary size of the lzma presets
+const maxLZMADictCap = 64 << 20
+
+// maxDecompressedSize limits the size of a single decompressed section, real
+// firmware volumes are way smaller.
+const maxDecompressedSize = 256 << 20
+
+// decodeGUIDDefined returns the content of a GUID-defined section with the
+// given GUID and attributes.
+func decodeGUIDDefined(guid GUID, attributes uint16, data []byte) ([]byte, error) {
+	switch guid {
+	case LZMACustomDecompressGUID:
+		return decompressLZMA(data)
+	case LZMAF86CustomDecompressGUID:
+		out, err := decompressLZMA(data)
+		if err != nil {
+			return nil, err
+		}
+		x86Decode(out)
+		return out, nil
+	case CRC32GUID:
+		return data, nil
+	}
+	if attributes&guidedSectionProcessingRequired == 0 {
+		return data, nil
+	}
+	switch guid {
+	case TianoCustomDecompressGUID:
+		return nil, errors.New("unsupported Tiano compression")
+	case BrotliCustomDecompressGUID:
+		return nil, errors.New("unsupported Brotli compression")
+	default:
+		return nil, fmt.Errorf("unsupported GUID-defined section %s", guid)
+	}
+}
+
+// decompressLZMA decompresses data in the legacy .lzma format EDK2 uses.
+func decompressLZMA(data []byte) ([]byte, error) {
+	if len(data) < lzma.HeaderLen {
+		return nil, io.ErrUnexpectedEOF
+	}
+	// the lzma reader allocates the dictionary size from the stream header
+	// up front, don't let a broken header make it allocate GiBs
+	if binary.LittleEndian.Uint32(data[1:5]) > maxLZMADictCap {
+		return nil, errors.New("lzma dictionary size too large")
+	}
+	size := binary.LittleEndian.Uint64(data[5:13])
+	if size > maxDecompressedSize {
+		return nil, fmt.Errorf("lzma decompressed size %d too large", size)
+	}
+	rd, err := lzma.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("lzma: %w", err)
+	}
+	out := make([]byte, size)
+	if _, err := io.ReadFull(rd, out); err != nil {
+		return nil, fmt.Errorf("lzma: %w", err)
+	}
+	return out, nil
+}
+
+// x86Decode reverts the x86 BCJ filter applied by LzmaF86 compression in
+// place. It is a port of x86_Convert from the LZMA SDK.
+func x86Decode(data []byte) {
+	maskToAllowed := [8]bool{true, true, true, false, true, false, false, false}
+	maskToBitNumber := [8]uint32{0, 1, 2, 2, 3, 3, 3, 3}
+	test86MSByte := func(b byte) bool {
+		return b == 0 || b == 0xff
+	}
+	if len(data) < 5 {
+		return
+	}
+
+	const ip = 5
+	var prevMask uint32
+	pos := 0
+	prevPos := -1
+	for {
+		limit := len(data) - 4
+		for pos < limit && data[pos]&0xfe != 0xe8 {
+			pos++
+		}
+		if pos >= limit {
+			break
+		}
+		p := data[pos:]
+		if d := pos - prevPos; d > 3 {
+			prevMask = 0
+		} else {
+			prevMask = (prevMask << (d - 1)) & 7
+			if prevMask != 0 {
+				b := p[4-maskToBitNumber[prevMask]]
+				if !maskToAllowed[prevMask] || test86MSByte(b) {
+					prevPos = pos
+					prevMask = ((prevMask << 1) & 7) | 1
+					pos++
+					continue
+				}
+			}
+		}
+		prevPos = pos
+
+		if !test86MSByte(p[4]) {
+			prevMask = ((prevMask << 1) & 7) | 1
+			pos++
+			continue
+		}
+		src := binary.LittleEndian.Uint32(p[1:5])
+		var dest uint32
+		for {
+			dest = src - (ip + uint32(pos))
+			if prevMask == 0 {
+				break
+			}
+			index := maskToBitNumber[prevMask] * 8
+			if !test86MSByte(byte(dest >> (24 - index))) {
+				break
+			}
+			src = dest ^ (1<<(32-index) - 1)
+		}
+		dest &= 0x01ffffff
+		if dest&0x01000000 != 0 {
+			dest |= 0xff000000
+		}
+		binary.LittleEndian.PutUint32(p[1:5], dest)
+		pos += 5
+	}
+}
@@ -0,0 +1,51 @@
+package uefi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecompressLZMA(t *testing.T) {
+	data := compressLZMA(t, fixtureLZMA)
+	got, err := decompressLZMA(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, fixtureLZMA) {
+		t.Errorf("decompressLZMA = %q, want %q", got, fixtureLZMA)
+	}
+
+	hugeDict := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(hugeDict[1:5], maxLZMADictCap+1)
+	hugeSize := append([]byte{}, data...)
+	binary.LittleEndian.PutUint64(hugeSize[5:13], maxDecompressedSize+1)
+	longer := append([]byte{}, data...)
+	binary.LittleEndian.PutUint64(longer[5:13], uint64(len(fixtureLZMA)+1))
+	for name, data := range map[string][]byte{
+		"header only":     data[:5],
+		"truncated":       data[:len(data)/2],
+		"huge dictionary": hugeDict,
+		"huge size":       hugeSize,
+		"size too large":  longer,
+	} {
+		if _, err := decompressLZMA(data); err == nil {
+			t.Errorf("%s: decompressLZMA succeeded", name)
+		}
+	}
+}
+
+func TestDecodeGUIDDefinedLZMAF86(t *testing.T) {
+	// a call at offset 8 to 0x100, its target converted to the absolute
+	// address 0x100+8+5 by the BCJ filter
+	want := []byte{0x90, 0x90, 0x90, 0x90, 0x90, 0x90, 0x90, 0x90, 0xe8, 0x00, 0x01, 0x00, 0x00, 0xc3}
+	filtered := append([]byte{}, want...)
+	binary.LittleEndian.PutUint32(filtered[9:], 0x10d)
+	got, err := decodeGUIDDefined(LZMAF86CustomDecompressGUID, guidedSectionProcessingRequired, compressLZMA(t, filtered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decoded % x, want % x", got, want)
+	}
+}
@@ -0,0 +1,478 @@
+package uefi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// firmware file system GUIDs, volumes with other file systems (e.g. the
+// variable store) don't contain FFS files
+var (
+	FFS1GUID = mustParseGUID("7A9354D9-0468-444A-81CE-0BF617D890DF")
+	FFS2GUID = mustParseGUID("8C8CE578-8A3D-4F1C-9935-896185C32DD3")
+	FFS3GUID = mustParseGUID("5473C07A-3DCB-4DCA-BD6F-1E9689E7349A")
+)
+
+var volumeSignature = []byte("_FVH")
+
+const (
+	volumeSignatureOffset = 40
+	volumeHeaderSize      = 56
+	fileHeaderSize        = 24
+	fileHeaderSize2       = 32 // large files of FFS3
+	sectionHeaderSize     = 4
+	sectionHeaderSize2    = 8 // sections larger than 16 MiB
+
+	attrErasePolarity = 0x00000800
+	attrLargeFile     = 0x01
+
+	// encapsulation sections can nest, stop at some point
+	maxDepth = 8
+)
+
+// file states, see GetFileState in EDK2
+const (
+	fileDataValid       = 0x04
+	fileMarkedForUpdate = 0x08
+)
+
+// FileType is the type of an FFS file.
+type FileType uint8
+
+const (
+	FileTypeRaw                 FileType = 0x01
+	FileTypeFreeform            FileType = 0x02
+	FileTypeSecurityCore        FileType = 0x03
+	FileTypePEICore             FileType = 0x04
+	FileTypeDXECore             FileType = 0x05
+	FileTypePEIM                FileType = 0x06
+	FileTypeDriver              FileType = 0x07
+	FileTypeCombinedPEIMDriver  FileType = 0x08
+	FileTypeApplication         FileType = 0x09
+	FileTypeMM                  FileType = 0x0a
+	FileTypeFirmwareVolumeImage FileType = 0x0b
+	FileTypeCombinedMMDXE       FileType = 0x0c
+	FileTypeMMCore              FileType = 0x0d
+	FileTypeMMStandalone        FileType = 0x0e
+	FileTypeMMCoreStandalone    FileType = 0x0f
+	FileTypePad                 FileType = 0xf0
+)
+
+// hasSections reports whether files of this type consist of sections. All
+// other files (raw, pad and OEM types) are just data.
+func (t FileType) hasSections() bool {
+	return t >= FileTypeFreeform && t <= FileTypeMMCoreStandalone
+}
+
+// SectionType is the type of an FFS section.
+type SectionType uint8
+
+const (
+	SectionCompression     SectionType = 0x01
+	SectionGUIDDefined     SectionType = 0x02
+	SectionDisposable      SectionType = 0x03
+	SectionPE32            SectionType = 0x10
+	SectionPIC             SectionType = 0x11
+	SectionTE              SectionType = 0x12
+	SectionDXEDepex        SectionType = 0x13
+	SectionVersion         SectionType = 0x14
+	SectionUserInterface   SectionType = 0x15
+	SectionCompatibility16 SectionType = 0x16
+	SectionFirmwareVolume  SectionType = 0x17
+	SectionFreeformSubtype SectionType = 0x18
+	SectionRaw             SectionType = 0x19
+	SectionPEIDepex        SectionType = 0x1b
+	SectionMMDepex         SectionType = 0x1c
+)
+
+// Volume is a firmware volume.
+type Volume struct {
+	Offset     int64 // offset of the volume in the image or in the section holding it
+	FileSystem GUID
+	Name       GUID // from the extended header, zero if there is none
+	Length     uint64
+	Attributes uint32
+	Files      []File
+}
+
+// File is a file of the firmware file system.
+type File struct {
+	Offset     int64 // offset of the file header in the volume
+	Name       GUID
+	Type       FileType
+	Attributes uint8
+	Data       []byte    // file data without header
+	Sections   []Section // nil for files which don't consist of sections
+}
+
+// Section is a section of an FFS file. Encapsulation sections (compression,
+// GUID-defined and firmware volume sections) are decoded, their content is
+// available in Sections or Volume.
+type Section struct {
+	Type     SectionType
+	GUID     GUID   // for GUID-defined and freeform subtype sections
+	Data     []byte // section data, decompressed for encapsulation sections
+	Sections []Section
+	Volume   *Volume // for firmware volume sections
+	Err      error   // set if an encapsulation section could not be decoded
+}
+
+func align(n, a int64) int64 {
+	return (n + a - 1) &^ (a - 1)
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// checkVolumeHeader validates the header of the volume at the start of
+// header and returns its length.
+func checkVolumeHeader(header []byte) (uint64, error) {
+	if len(header) < volumeHeaderSize || !bytes.Equal(header[volumeSignatureOffset:volumeSignatureOffset+4], volumeSignature) {
+		return 0, errors.New("missing firmware volume signature")
+	}
+	length := binary.LittleEndian.Uint64(header[32:40])
+	headerLength := int(binary.LittleEndian.Uint16(header[48:50]))
+	if headerLength < volumeHeaderSize || headerLength%2 != 0 || uint64(headerLength) > length {
+		return 0, fmt.Errorf("invalid firmware volume header length %d", headerLength)
+	}
+	if headerLength > len(header) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var sum uint16
+	for i := 0; i < headerLength; i += 2 {
+		sum += binary.LittleEndian.Uint16(header[i:])
+	}
+	if sum != 0 {
+		return 0, errors.New("invalid firmware volume header checksum")
+	}
+	return length, nil
+}
+
+// ParseVolume parses the firmware volume at the start of data.
+func ParseVolume(data []byte) (*Volume, error) {
+	return parseVolume(data, 0)
+}
+
+func parseVolume(data []byte, depth int) (*Volume, error) {
+	length, err := checkVolumeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(data)) {
+		return nil, fmt.Errorf("firmware volume of %d bytes exceeds data", length)
+	}
+	data = data[:length]
+	v := &Volume{
+		FileSystem: readGUID(data[16:32]),
+		Length:     length,
+		Attributes: binary.LittleEndian.Uint32(data[44:48]),
+	}
+	if v.FileSystem != FFS1GUID && v.FileSystem != FFS2GUID && v.FileSystem != FFS3GUID {
+		return v, nil
+	}
+
+	offset := int64(binary.LittleEndian.Uint16(data[48:50]))
+	if ext := int64(binary.LittleEndian.Uint16(data[52:54])); ext != 0 {
+		if ext+20 > int64(len(data)) {
+			return nil, errors.New("firmware volume extended header exceeds volume")
+		}
+		v.Name = readGUID(data[ext : ext+16])
+		offset = ext + int64(binary.LittleEndian.Uint32(data[ext+16:ext+20]))
+	}
+	erased := byte(0x00)
+	if v.Attributes&attrErasePolarity != 0 {
+		erased = 0xff
+	}
+	for offset = align(offset, 8); offset+fileHeaderSize <= int64(len(data)); offset = align(offset, 8) {
+		header := data[offset : offset+fileHeaderSize]
+		if bytes.Count(header, []byte{erased}) == fileHeaderSize {
+			// free space
+			break
+		}
+		f, size, err := parseFile(data[offset:], v.FileSystem == FFS3GUID, erased, depth)
+		if err != nil {
+			return v, fmt.Errorf("FFS file at %#x: %w", offset, err)
+		}
+		offset += size
+		if f == nil {
+			continue
+		}
+		f.Offset = offset - size
+		v.Files = append(v.Files, *f)
+	}
+	return v, nil
+}
+
+// parseFile parses the FFS file at the start of data and returns it along with
+// its size. The returned file is nil for deleted or incomplete files.
+func parseFile(data []byte, ffs3 bool, erased byte, depth int) (*File, int64, error) {
+	f := &File{
+		Name:       readGUID(data[0:16]),
+		Type:       FileType(data[18]),
+		Attributes: data[19],
+	}
+	size := int64(uint24(data[20:23]))
+	headerSize := int64(fileHeaderSize)
+	if ffs3 && f.Attributes&attrLargeFile != 0 {
+		if len(data) < fileHeaderSize2 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		size = int64(binary.LittleEndian.Uint64(data[24:32]))
+		headerSize = fileHeaderSize2
+	}
+	if size < headerSize || size > int64(len(data)) {
+		return nil, 0, fmt.Errorf("invalid file size %#x", size)
+	}
+
+	state := data[23]
+	if erased != 0 {
+		state = ^state
+	}
+	// the highest bit set tells the state
+	highest := byte(0)
+	for bit := byte(0x80); bit != 0; bit >>= 1 {
+		if state&bit != 0 {
+			highest = bit
+			break
+		}
+	}
+	if highest != fileDataValid && highest != fileMarkedForUpdate {
+		return nil, size, nil
+	}
+
+	f.Data = data[headerSize:size]
+	if f.Type.hasSections() {
+		f.Sections = parseSections(f.Data, depth)
+	}
+	return f, size, nil
+}
+
+// parseSections parses all sections in data. Sections which can't be parsed
+// end the list, encapsulation sections which can't be decoded have Err set.
+func parseSections(data []byte, depth int) []Section {
+	var sections []Section
+	for offset := int64(0); offset+sectionHeaderSize <= int64(len(data)); offset = align(offset, 4) {
+		size := int64(uint24(data[offset : offset+3]))
+		headerSize := int64(sectionHeaderSize)
+		if size == 0xffffff {
+			if offset+sectionHeaderSize2 > int64(len(data)) {
+				break
+			}
+			size = int64(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+			headerSize = sectionHeaderSize2
+		}
+		if size < headerSize || size > int64(len(data))-offset {
+			break
+		}
+		s := Section{Type: SectionType(data[offset+3])}
+		s.Data = data[offset+headerSize : offset+size]
+		s.decode(int(headerSize), depth)
+		sections = append(sections, s)
+		offset += size
+	}
+	return sections
+}
+
+// decode decodes the content of encapsulation sections.
+// headerSize is the size of the section header, which was already stripped
+// from s.Data.
+func (s *Section) decode(headerSize int, depth int) {
+	switch s.Type {
+	case SectionCompression, SectionGUIDDefined, SectionFirmwareVolume:
+		if depth >= maxDepth {
+			s.Err = errors.New("sections nested too deeply")
+			return
+		}
+	}
+	switch s.Type {
+	case SectionCompression:
+		if len(s.Data) < 5 {
+			s.Err = io.ErrUnexpectedEOF
+			return
+		}
+		switch s.Data[4] {
+		case 0:
+			s.Data = s.Data[5:]
+			s.Sections = parseSections(s.Data, depth+1)
+		default:
+			s.Err = fmt.Errorf("unsupported compression type %d", s.Data[4])
+		}
+	case SectionGUIDDefined:
+		if len(s.Data) < 20 {
+			s.Err = io.ErrUnexpectedEOF
+			return
+		}
+		s.GUID = readGUID(s.Data[0:16])
+		// DataOffset counts from the start of the section header
+		dataOffset := int(binary.LittleEndian.Uint16(s.Data[16:18])) - headerSize
+		attributes := binary.LittleEndian.Uint16(s.Data[18:20])
+		if dataOffset < 20 || dataOffset > len(s.Data) {
+			s.Err = fmt.Errorf("invalid data offset %#x", dataOffset+headerSize)
+			return
+		}
+		data, err := decodeGUIDDefined(s.GUID, attributes, s.Data[dataOffset:])
+		if err != nil {
+			s.Err = err
+			return
+		}
+		s.Data = data
+		s.Sections = parseSections(s.Data, depth+1)
+	case SectionFirmwareVolume:
+		v, err := parseVolume(s.Data, depth+1)
+		if err != nil {
+			s.Err = err
+		}
+		s.Volume = v
+	case SectionFreeformSubtype:
+		if len(s.Data) >= 16 {
+			s.GUID = readGUID(s.Data[0:16])
+			s.Data = s.Data[16:]
+		}
+	}
+}
+
+// FindVolumes returns all firmware volumes found in the first size bytes of r.
+// Only the volumes are read into memory, not the whole image.
+func FindVolumes(r io.ReaderAt, size int64) ([]Volume, error) {
+	var volumes []Volume
+	chunk := make([]byte, 1<<20)
+	for pos := int64(0); pos < size; {
+		n, err := r.ReadAt(chunk, pos)
+		if err != nil && err != io.EOF {
+			return volumes, err
+		}
+		if n < len(volumeSignature) {
+			break
+		}
+		next := pos + int64(n-len(volumeSignature)+1)
+		for i := 0; ; {
+			j := bytes.Index(chunk[i:n], volumeSignature)
+			if j == -1 {
+				break
+			}
+			offset := pos + int64(i+j) - volumeSignatureOffset
+			i += j + 1
+			if offset < 0 {
+				continue
+			}
+			v, err := readVolume(r, size, offset)
+			if err != nil {
+				// _FVH might just show up in some code or data
+				continue
+			}
+			volumes = append(volumes, *v)
+			// nested volumes are part of the parsed volume already
+			end := offset + int64(v.Length)
+			if end > pos+int64(n) {
+				next = end
+				break
+			}
+			i = int(end - pos)
+		}
+		pos = next
+	}
+	return volumes, nil
+}
+
+func readVolume(r io.ReaderAt, size int64, offset int64) (*Volume, error) {
+	header := make([]byte, volumeHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return nil, err
+	}
+	headerLength := int64(binary.LittleEndian.Uint16(header[48:50]))
+	if headerLength > volumeHeaderSize && offset+headerLength <= size {
+		header = make([]byte, headerLength)
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, err
+		}
+	}
+	length, err := checkVolumeHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(size-offset) {
+		return nil, fmt.Errorf("firmware volume at %#x exceeds image", offset)
+	}
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+	v, err := ParseVolume(data)
+	if err != nil {
+		return nil, fmt.Errorf("firmware volume at %#x: %w", offset, err)
+	}
+	v.Offset = offset
+	return v, nil
+}
+
+// Walk calls fn for the data of every raw file and every leaf section in
+// volumes, including those of nested volumes. file is the innermost FFS file
+// holding the data. Walk stops at the first error returned by fn.
+func Walk(volumes []Volume, fn func(file *File, data []byte) error) error {
+	for i := range volumes {
+		for j := range volumes[i].Files {
+			f := &volumes[i].Files[j]
+			if !f.Type.hasSections() {
+				if err := fn(f, f.Data); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := walkSections(f, f.Sections, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func walkSections(f *File, sections []Section, fn func(file *File, data []byte) error) error {
+	for i := range sections {
+		s := &sections[i]
+		switch {
+		case s.Volume != nil:
+			if err := Walk([]Volume{*s.Volume}, fn); err != nil {
+				return err
+			}
+		case s.Type == SectionCompression || s.Type == SectionGUIDDefined:
+			if err := walkSections(f, s.Sections, fn); err != nil {
+				return err
+			}
+		default:
+			if err := fn(f, s.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Undecoded calls fn for every encapsulation section in volumes, including
+// those of nested volumes, which could not be decoded (see Section.Err). Walk
+// skips their content, which might hold anything. file is the innermost FFS
+// file holding the section.
+func Undecoded(volumes []Volume, fn func(file *File, s *Section)) {
+	for i := range volumes {
+		for j := range volumes[i].Files {
+			f := &volumes[i].Files[j]
+			undecodedSections(f, f.Sections, fn)
+		}
+	}
+}
+
+func undecodedSections(f *File, sections []Section, fn func(file *File, s *Section)) {
+	for i := range sections {
+		s := &sections[i]
+		if s.Err != nil {
+			fn(f, s)
+		}
+		if s.Volume != nil {
+			Undecoded([]Volume{*s.Volume}, fn)
+		}
+		undecodedSections(f, s.Sections, fn)
+	}
+}
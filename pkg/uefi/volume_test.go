@@ -0,0 +1,318 @@
+package uefi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+var (
+	rawFileGUID  = mustParseGUID("11111111-2222-3333-4444-555555555555")
+	driverGUID   = mustParseGUID("66666666-7777-8888-9999-AAAAAAAAAAAA")
+	nestedGUID   = mustParseGUID("BBBBBBBB-CCCC-DDDD-EEEE-FFFFFFFFFFFF")
+	unknownGUID  = mustParseGUID("01234567-89AB-CDEF-0123-456789ABCDEF")
+	volumeName   = mustParseGUID("FEDCBA98-7654-3210-FEDC-BA9876543210")
+	fixtureRaw   = []byte("raw file data")
+	fixturePlain = []byte("data of a plain raw section")
+	fixtureLZMA  = bytes.Repeat([]byte("data of an LZMA compressed section "), 4)
+)
+
+// buildSection returns a section of type typ holding data, padded to the 4
+// byte alignment of sections.
+func buildSection(typ SectionType, data []byte) []byte {
+	s := make([]byte, sectionHeaderSize, sectionHeaderSize+len(data)+3)
+	size := uint32(len(s) + len(data))
+	s[0], s[1], s[2], s[3] = byte(size), byte(size>>8), byte(size>>16), byte(typ)
+	s = append(s, data...)
+	for len(s)%4 != 0 {
+		s = append(s, 0)
+	}
+	return s
+}
+
+// buildGUIDSection returns a GUID-defined section holding data.
+func buildGUIDSection(guid GUID, attributes uint16, data []byte) []byte {
+	header := make([]byte, 20)
+	copy(header, guid[:])
+	binary.LittleEndian.PutUint16(header[16:], sectionHeaderSize+20)
+	binary.LittleEndian.PutUint16(header[18:], attributes)
+	return buildSection(SectionGUIDDefined, append(header, data...))
+}
+
+func compressLZMA(t testing.TB, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := lzma.WriterConfig{Size: int64(len(data))}.NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// buildFile returns a valid FFS file of an erase polarity 1 volume, padded to
+// the 8 byte alignment of files with erased bytes.
+func buildFile(name GUID, typ FileType, data []byte) []byte {
+	f := make([]byte, fileHeaderSize, fileHeaderSize+len(data)+7)
+	copy(f, name[:])
+	size := uint32(len(f) + len(data))
+	f[18] = byte(typ)
+	f[20], f[21], f[22] = byte(size), byte(size>>8), byte(size>>16)
+	// header construction, header valid and data valid, inverted
+	f[23] = ^byte(0x07)
+	f = append(f, data...)
+	for len(f)%8 != 0 {
+		f = append(f, 0xff)
+	}
+	return f
+}
+
+// buildVolume returns an FFS2 firmware volume of erase polarity 1 holding
+// files, followed by free space. If name is not zero, the volume has an
+// extended header with that name.
+func buildVolume(name GUID, files ...[]byte) []byte {
+	headerLength := volumeHeaderSize + 16 // one block map entry and the terminator
+	v := make([]byte, headerLength)
+	copy(v[16:32], FFS2GUID[:])
+	copy(v[volumeSignatureOffset:], volumeSignature)
+	binary.LittleEndian.PutUint32(v[44:], attrErasePolarity)
+	binary.LittleEndian.PutUint16(v[48:], uint16(headerLength))
+	v[55] = 2 // revision
+	if name != (GUID{}) {
+		binary.LittleEndian.PutUint16(v[52:], uint16(len(v)))
+		ext := make([]byte, 20)
+		copy(ext, name[:])
+		binary.LittleEndian.PutUint32(ext[16:], uint32(len(ext)))
+		v = append(v, ext...)
+	}
+	for len(v)%8 != 0 {
+		v = append(v, 0xff)
+	}
+	for _, f := range files {
+		v = append(v, f...)
+	}
+	v = append(v, bytes.Repeat([]byte{0xff}, 64)...)
+	for len(v)%0x100 != 0 {
+		v = append(v, 0xff)
+	}
+	binary.LittleEndian.PutUint64(v[32:], uint64(len(v)))
+	binary.LittleEndian.PutUint32(v[56:], uint32(len(v)/0x100))
+	binary.LittleEndian.PutUint32(v[60:], 0x100)
+	var sum uint16
+	for i := 0; i < headerLength; i += 2 {
+		sum += binary.LittleEndian.Uint16(v[i:])
+	}
+	binary.LittleEndian.PutUint16(v[50:], -sum)
+	return v
+}
+
+// fixtureVolume holds a raw file, a driver with a plain and an LZMA compressed
+// raw section and a file with a nested volume holding another raw file.
+func fixtureVolume(t testing.TB) []byte {
+	compressed := buildSection(SectionRaw, fixtureLZMA)
+	driver := append(buildSection(SectionRaw, fixturePlain),
+		buildGUIDSection(LZMACustomDecompressGUID, guidedSectionProcessingRequired, compressLZMA(t, compressed))...)
+	nested := buildVolume(GUID{}, buildFile(rawFileGUID, FileTypeRaw, []byte("nested raw file")))
+	return buildVolume(volumeName,
+		buildFile(rawFileGUID, FileTypeRaw, fixtureRaw),
+		buildFile(driverGUID, FileTypeDriver, driver),
+		buildFile(nestedGUID, FileTypeFirmwareVolumeImage, buildSection(SectionFirmwareVolume, nested)),
+	)
+}
+
+type walked struct {
+	file GUID
+	data string
+}
+
+func walkAll(t *testing.T, volumes []Volume) []walked {
+	t.Helper()
+	var all []walked
+	err := Walk(volumes, func(f *File, data []byte) error {
+		all = append(all, walked{f.Name, string(data)})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return all
+}
+
+func TestParseVolume(t *testing.T) {
+	v, err := ParseVolume(fixtureVolume(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.FileSystem != FFS2GUID || v.Name != volumeName {
+		t.Errorf("volume file system %s name %s, want %s %s", v.FileSystem, v.Name, FFS2GUID, volumeName)
+	}
+	if len(v.Files) != 3 {
+		t.Fatalf("got %d files, want 3", len(v.Files))
+	}
+	driver := v.Files[1]
+	if driver.Name != driverGUID || driver.Type != FileTypeDriver || len(driver.Sections) != 2 {
+		t.Fatalf("driver = %+v", driver)
+	}
+	lzmaSection := driver.Sections[1]
+	if lzmaSection.Type != SectionGUIDDefined || lzmaSection.GUID != LZMACustomDecompressGUID || lzmaSection.Err != nil {
+		t.Errorf("LZMA section = %s %v", lzmaSection.GUID, lzmaSection.Err)
+	}
+	if nested := v.Files[2].Sections; len(nested) != 1 || nested[0].Volume == nil || len(nested[0].Volume.Files) != 1 {
+		t.Errorf("nested volume section = %+v", nested)
+	}
+
+	want := []walked{
+		{rawFileGUID, string(fixtureRaw)},
+		{driverGUID, string(fixturePlain)},
+		{driverGUID, string(fixtureLZMA)},
+		{rawFileGUID, "nested raw file"},
+	}
+	if got := walkAll(t, []Volume{*v}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk = %v, want %v", got, want)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = Walk([]Volume{*v}, func(f *File, data []byte) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Walk = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
+
+func TestParseVolumeInvalid(t *testing.T) {
+	fv := fixtureVolume(t)
+	badChecksum := append([]byte{}, fv...)
+	badChecksum[50]++
+	badFile := append([]byte{}, fv...)
+	// the size of the first file, following the headers, exceeds the volume
+	badFile[align(volumeHeaderSize+16+20, 8)+22] = 0x7f
+	for name, data := range map[string][]byte{
+		"no signature":  fv[:volumeSignatureOffset],
+		"checksum":      badChecksum,
+		"truncated":     fv[:len(fv)-1],
+		"file too long": badFile,
+	} {
+		if _, err := ParseVolume(data); err == nil {
+			t.Errorf("%s: ParseVolume succeeded", name)
+		}
+	}
+}
+
+func TestSectionErrors(t *testing.T) {
+	deep := buildSection(SectionRaw, []byte("too deep"))
+	for i := 0; i <= maxDepth; i++ {
+		deep = buildGUIDSection(CRC32GUID, 0, deep)
+	}
+	for _, test := range []struct {
+		name    string
+		section []byte
+	}{
+		{"Tiano", buildGUIDSection(TianoCustomDecompressGUID, guidedSectionProcessingRequired, []byte("compressed"))},
+		{"Brotli", buildGUIDSection(BrotliCustomDecompressGUID, guidedSectionProcessingRequired, []byte("compressed"))},
+		{"unknown GUID", buildGUIDSection(unknownGUID, guidedSectionProcessingRequired, []byte("encrypted"))},
+		{"broken LZMA", buildGUIDSection(LZMACustomDecompressGUID, guidedSectionProcessingRequired, []byte("no lzma"))},
+		{"EFI compression", buildSection(SectionCompression, []byte{0, 0, 0, 0, 1})},
+		{"nested too deeply", deep},
+	} {
+		sections := parseSections(test.section, 0)
+		for len(sections) == 1 && sections[0].Err == nil {
+			sections = sections[0].Sections
+		}
+		if len(sections) != 1 || sections[0].Err == nil {
+			t.Errorf("%s: sections = %+v, want one with Err set", test.name, sections)
+		}
+	}
+
+	// sections of unknown GUID which need no processing are just data
+	sections := parseSections(buildGUIDSection(unknownGUID, 0, buildSection(SectionRaw, []byte("signed"))), 0)
+	if len(sections) != 1 || sections[0].Err != nil || len(sections[0].Sections) != 1 || string(sections[0].Sections[0].Data) != "signed" {
+		t.Errorf("unprocessed GUID-defined section = %+v", sections)
+	}
+}
+
+func TestFindVolumes(t *testing.T) {
+	fv := fixtureVolume(t)
+	image := bytes.Repeat([]byte{0xff}, 0x1000)
+	// some code referring to the signature
+	copy(image[0x100:], volumeSignature)
+	image = append(image, fv...)
+	image = append(image, bytes.Repeat([]byte{0xff}, 0x100)...)
+	image = append(image, buildVolume(GUID{}, buildFile(unknownGUID, FileTypeRaw, []byte("second volume")))...)
+
+	volumes, err := FindVolumes(bytes.NewReader(image), int64(len(image)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 2 || volumes[0].Offset != 0x1000 || volumes[1].Offset != int64(0x1100+len(fv)) {
+		t.Fatalf("volumes = %+v", volumes)
+	}
+	got := walkAll(t, volumes)
+	if len(got) != 5 || got[4] != (walked{unknownGUID, "second volume"}) {
+		t.Errorf("Walk = %v", got)
+	}
+
+	// volumes exceeding the image are skipped, the size ends before the
+	// nested volume
+	volumes, err = FindVolumes(bytes.NewReader(image), 0x1100)
+	if err != nil || len(volumes) != 0 {
+		t.Errorf("FindVolumes of a truncated image = %+v, %v", volumes, err)
+	}
+}
+
+func TestGUID(t *testing.T) {
+	const s = "8C8CE578-8A3D-4F1C-9935-896185C32DD3"
+	g, err := ParseGUID(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.String() != s {
+		t.Errorf("String = %s, want %s", g, s)
+	}
+	if want := []byte{0x78, 0xe5, 0x8c, 0x8c, 0x3d, 0x8a, 0x1c, 0x4f, 0x99, 0x35}; !bytes.HasPrefix(g[:], want) {
+		t.Errorf("GUID bytes = % x, want % x...", g[:], want)
+	}
+	for _, bad := range []string{"", "8C8CE578-8A3D-4F1C-9935", "8C8CE578-8A3D-4F1C-9935-896185C32DDX"} {
+		if _, err := ParseGUID(bad); err == nil {
+			t.Errorf("ParseGUID(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestUndecoded(t *testing.T) {
+	tiano := buildGUIDSection(TianoCustomDecompressGUID, guidedSectionProcessingRequired, []byte("compressed"))
+	nested := buildVolume(GUID{}, buildFile(driverGUID, FileTypeDriver, buildGUIDSection(CRC32GUID, 0, tiano)))
+	fv := buildVolume(GUID{},
+		buildFile(rawFileGUID, FileTypeRaw, fixtureRaw),
+		buildFile(nestedGUID, FileTypeFirmwareVolumeImage, buildSection(SectionFirmwareVolume, nested)),
+	)
+	v, err := ParseVolume(fv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []GUID
+	Undecoded([]Volume{*v}, func(f *File, s *Section) {
+		if s.GUID != TianoCustomDecompressGUID || s.Err == nil {
+			t.Errorf("reported section %s with error %v", s.GUID, s.Err)
+		}
+		files = append(files, f.Name)
+	})
+	if want := []GUID{driverGUID}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Undecoded reported files %v, want %v", files, want)
+	}
+	// Walk skips the content of the undecoded section
+	if got := walkAll(t, []Volume{*v}); len(got) != 1 {
+		t.Errorf("Walk = %v, want only the raw file", got)
+	}
+}
@@ -0,0 +1,30 @@
+package uswid
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/9elements/goswid/pkg/cbfs"
+)
+
+// FromCBFS decodes the uSWID data of all CBFS files called name (usually
+// cbfs.DefaultSBOMName) in the first size bytes of r and returns these files.
+// An image can contain several CBFS (e.g. for A/B updates), so there might be
+// more than one. If there is no such file, no error is returned.
+func (uswid *UswidSoftwareIdentity) FromCBFS(r io.ReaderAt, size int64, name string) ([]cbfs.File, error) {
+	files, err := cbfs.Parse(r, size)
+	if err != nil {
+		return nil, err
+	}
+	found := cbfs.Find(files, name)
+	for _, f := range found {
+		content, err := f.Content()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := uswid.FromImage(content); err != nil {
+			return nil, fmt.Errorf("CBFS file %q at %#x: %w", f.Name, f.Offset, err)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,247 @@
+package uswid
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned by codecs which can't decode or encode.
+var ErrUnsupported = errors.New("not supported by this format")
+
+// ErrUnknownFormat is returned if no codec matches a format name, file
+// extension or file content.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Codec reads and writes tags in one file format.
+type Codec interface {
+	// Name is the name of the format, as used by --input-format and
+	// --output-format.
+	Name() string
+	// Extensions are the file extensions of the format without leading dot
+	// (e.g. "json" or "spdx.json").
+	Extensions() []string
+	// Sniff reports whether head, the leading bytes (up to SniffLen) of a
+	// file, looks like this format.
+	Sniff(head []byte) bool
+	// Decode reads all tags from r. It returns ErrUnsupported if the format
+	// can only be written.
+	Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error)
+	// Encode writes all tags of uswid to w. It returns ErrUnsupported if the
+	// format can only be read.
+	Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error
+}
+
+// CodecOptions are passed to the codecs. Settings of single codecs are
+// passed in Options.
+type CodecOptions struct {
+	// Format is the name of the codec to decode with, it is detected from
+	// the content if empty.
+	Format string
+	// Filename is the name of the file read or written, if there is one.
+	Filename string
+	// Options are codec specific settings, e.g. ImageOptions, USWIDOptions
+	// or GraphOptions. Codecs look up the types they know with Option and
+	// ignore all others.
+	Options []interface{}
+}
+
+// Option sets the value target points to to the first element of
+// opts.Options of the same type and reports whether there is one.
+func (opts CodecOptions) Option(target interface{}) bool {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		panic("uswid: Option target must be a non-nil pointer")
+	}
+	for _, o := range opts.Options {
+		if o != nil && reflect.TypeOf(o) == v.Type().Elem() {
+			v.Elem().Set(reflect.ValueOf(o))
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	codecsMu sync.RWMutex
+	// codecs in the order they are sniffed
+	codecs []Codec
+)
+
+// RegisterCodec makes a codec available to FromFile, the CLI and the lookup
+// functions. A codec replaces a registered codec of the same name and takes
+// precedence over all codecs registered before when sniffing file content.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	list := []Codec{c}
+	for _, old := range codecs {
+		if old.Name() != c.Name() {
+			list = append(list, old)
+		}
+	}
+	codecs = list
+}
+
+// Codecs returns all registered codecs in the order they are sniffed.
+func Codecs() []Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	return append([]Codec{}, codecs...)
+}
+
+// CodecByName returns the codec of the format called name.
+func CodecByName(name string) (Codec, error) {
+	for _, c := range Codecs() {
+		if strings.EqualFold(c.Name(), name) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFormat, name)
+}
+
+// CodecByExtension returns the codec for the extension of filename. The
+// longest matching extension wins, so "sbom.spdx.json" is not taken for plain
+// JSON if there is a codec for "spdx.json".
+func CodecByExtension(filename string) (Codec, error) {
+	base := strings.ToLower(filepath.Base(filename))
+	var found Codec
+	var foundLen int
+	for _, c := range Codecs() {
+		for _, ext := range c.Extensions() {
+			ext = "." + strings.ToLower(ext)
+			if strings.HasSuffix(base, ext) && len(ext) > foundLen {
+				found, foundLen = c, len(ext)
+			}
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: could not guess format by file extension of %s", ErrUnknownFormat, filename)
+	}
+	return found, nil
+}
+
+// SniffCodec returns the codec for a file starting with head (up to SniffLen
+// bytes). Binary data no codec recognizes is taken for an image, which is
+// searched for uSWID data.
+func SniffCodec(head []byte) (Codec, error) {
+	for _, c := range Codecs() {
+		if c.Sniff(head) {
+			return c, nil
+		}
+	}
+	if len(head) > 0 && !isText(head) {
+		return CodecByName(imageCodec{}.Name())
+	}
+	return nil, fmt.Errorf("%w: could not detect format from content", ErrUnknownFormat)
+}
+
+// refines reports whether the format of c is a special case of the format of
+// base, going by their extensions, like "spdx.json" of "json".
+func refines(c Codec, base Codec) bool {
+	if c.Name() == base.Name() {
+		return false
+	}
+	for _, ext := range c.Extensions() {
+		for _, baseExt := range base.Extensions() {
+			if strings.HasSuffix(strings.ToLower(ext), "."+strings.ToLower(baseExt)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Decode reads all tags from r and adds them to uswid. The format is taken
+// from opts.Format or detected from the content. Content that no codec
+// recognizes is decoded by the extension of opts.Filename, which also picks
+// a more specific codec for the sniffed format, e.g. spdx-json for JSON.
+func (uswid *UswidSoftwareIdentity) Decode(r io.Reader, opts CodecOptions) error {
+	var codec Codec
+	var err error
+	if opts.Format != "" {
+		codec, err = CodecByName(opts.Format)
+		if err != nil {
+			return err
+		}
+	} else {
+		var head []byte
+		if ra, ok := r.(io.ReaderAt); ok {
+			// don't consume anything, images want to read at offsets
+			head = make([]byte, SniffLen)
+			n, err := ra.ReadAt(head, 0)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			head = head[:n]
+		} else {
+			br := bufio.NewReaderSize(r, SniffLen)
+			head, err = br.Peek(SniffLen)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			r = br
+		}
+		byExt, extErr := CodecByExtension(opts.Filename)
+		if codec, err = SniffCodec(head); err != nil {
+			// the content gives no hint, maybe the file extension does
+			if extErr != nil {
+				return err
+			}
+			codec = byExt
+		} else if extErr == nil && refines(byExt, codec) {
+			// e.g. SPDX JSON whose spdxVersion comes after the sniffed
+			// bytes, which only looks like JSON
+			codec = byExt
+		}
+	}
+
+	if opts.Format == "" && codec.Name() == (cborCodec{}).Name() {
+		// binary data might just happen to start like CBOR, search it for
+		// uSWID data if it isn't
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		tags, err := codec.Decode(bytes.NewReader(data), opts)
+		if err != nil {
+			tags, err = imageCodec{}.Decode(bytes.NewReader(data), opts)
+		}
+		uswid.Identities = append(uswid.Identities, tags.Identities...)
+		return err
+	}
+	tags, err := codec.Decode(r, opts)
+	uswid.Identities = append(uswid.Identities, tags.Identities...)
+	return err
+}
+
+// readerAt returns r as io.ReaderAt along with its size. Files and readers
+// which know their size are used as they are, everything else is read into
+// memory.
+func readerAt(r io.Reader) (io.ReaderAt, int64, error) {
+	switch v := r.(type) {
+	case *os.File:
+		fi, err := v.Stat()
+		if err == nil && fi.Mode().IsRegular() {
+			return v, fi.Size(), nil
+		}
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return v, v.Size(), nil
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
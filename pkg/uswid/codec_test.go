@@ -0,0 +1,47 @@
+package uswid
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeFallsBackToExtension(t *testing.T) {
+	// variable lines only in the first SniffLen bytes, nothing to sniff
+	var pc strings.Builder
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&pc, "var%d=/usr/lib/foo/%d\n", i, i)
+	}
+	pc.WriteString("Name: foo\nDescription: the foo library\nVersion: 1.0\n")
+	if _, err := SniffCodec([]byte(pc.String())[:SniffLen]); err == nil {
+		t.Fatal("fixture is detected by content, the test is pointless")
+	}
+
+	var u UswidSoftwareIdentity
+	if err := u.Decode(strings.NewReader(pc.String()), CodecOptions{Filename: "foo.pc"}); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if names := softwareNames(u); !reflect.DeepEqual(names, []string{"foo"}) {
+		t.Errorf("software names = %v, want [foo]", names)
+	}
+
+	if err := u.Decode(strings.NewReader(pc.String()), CodecOptions{Filename: "foo.txt"}); err == nil {
+		t.Error("Decode of an unknown extension succeeded")
+	}
+}
+
+func TestDecodeRefinedByExtension(t *testing.T) {
+	// the spdxVersion comes too late to sniff the document as SPDX
+	doc := `{"SPDXID":"SPDXRef-DOCUMENT","name":"` + strings.Repeat("x", SniffLen) + `","spdxVersion":"SPDX-2.3",` +
+		`"documentNamespace":"https://example.com/doc","creationInfo":{"created":"2024-01-01T00:00:00Z","creators":["Organization: ACME"]},` +
+		`"packages":[{"SPDXID":"SPDXRef-foo","name":"foo","versionInfo":"1.0","downloadLocation":"NOASSERTION"}],` +
+		`"relationships":[{"spdxElementId":"SPDXRef-DOCUMENT","relationshipType":"DESCRIBES","relatedSpdxElement":"SPDXRef-foo"}]}`
+	var u UswidSoftwareIdentity
+	if err := u.Decode(strings.NewReader(doc), CodecOptions{Filename: "foo.spdx.json"}); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if names := softwareNames(u); !reflect.DeepEqual(names, []string{"foo"}) {
+		t.Errorf("software names = %v, want [foo]", names)
+	}
+}
@@ -0,0 +1,248 @@
+package uswid
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	// codecs registered later are sniffed first, so the most specific
+	// ones come last
+	for _, c := range []Codec{
+		plantUMLCodec{},
+		dotCodec{},
+		graphMLCodec{},
+		mermaidCodec{},
+		htmlCodec{},
+		cborCodec{},
+		pcCodec{},
+		jsonCodec{},
+		xmlCodec{},
+		// SBOM documents are told from plain JSON, XML and .pc files by markers
+		spdxJSONCodec{},
+		spdxTagValueCodec{},
+		cycloneDXJSONCodec{},
+		cycloneDXXMLCodec{},
+		imageCodec{},
+		compressedCodec{name: "xz", ext: "xz", magic: xzMagic},
+		compressedCodec{name: "gzip", ext: "gz", magic: gzipMagic},
+		uswidCodec{},
+	} {
+		RegisterCodec(c)
+	}
+}
+
+// writeAll writes the output of an encoder to w.
+func writeAll(w io.Writer, buf []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string         { return "json" }
+func (jsonCodec) Extensions() []string { return []string{"json"} }
+
+func (jsonCodec) Sniff(head []byte) bool {
+	text, ok := textHead(head)
+	// goswid allows comments in JSON files
+	return ok && (text[0] == '{' || text[0] == '[' || bytes.HasPrefix(text, []byte("//")) || bytes.HasPrefix(text, []byte("/*")))
+}
+
+func (jsonCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	var uswid UswidSoftwareIdentity
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return uswid, err
+	}
+	err = uswid.FromJSON(jsonMinify(string(bytes.TrimPrefix(data, utf8BOM)), false))
+	return uswid, err
+}
+
+func (jsonCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	buf, err := uswid.ToJSON()
+	return writeAll(w, buf, err)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Name() string         { return "xml" }
+func (xmlCodec) Extensions() []string { return []string{"xml", "swidtag"} }
+
+func (xmlCodec) Sniff(head []byte) bool {
+	text, ok := textHead(head)
+	return ok && text[0] == '<'
+}
+
+func (xmlCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	var uswid UswidSoftwareIdentity
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return uswid, err
+	}
+	err = uswid.FromXML(string(data))
+	return uswid, err
+}
+
+func (xmlCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	buf, err := uswid.ToXML()
+	return writeAll(w, buf, err)
+}
+
+type pcCodec struct{}
+
+func (pcCodec) Name() string         { return "pc" }
+func (pcCodec) Extensions() []string { return []string{"pc"} }
+
+func (pcCodec) Sniff(head []byte) bool {
+	text, ok := textHead(head)
+	return ok && isPC(text)
+}
+
+func (pcCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	var uswid UswidSoftwareIdentity
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return uswid, err
+	}
+	pcStr := strings.ReplaceAll(string(data), "\r\n", "\n") // replace windows line endings with line feeds
+	err = uswid.FromPC(pcStr, opts.Filename)
+	return uswid, err
+}
+
+func (pcCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	return ErrUnsupported
+}
+
+type cborCodec struct{}
+
+func (cborCodec) Name() string         { return "cbor" }
+func (cborCodec) Extensions() []string { return []string{"cbor", "coswid"} }
+
+func (cborCodec) Sniff(head []byte) bool {
+	_, text := textHead(head)
+	return !text && isCBOR(head)
+}
+
+func (cborCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	var uswid UswidSoftwareIdentity
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return uswid, err
+	}
+	err = uswid.FromCBORCompression(data, CompressionNone)
+	return uswid, err
+}
+
+func (cborCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	var o USWIDOptions
+	opts.Option(&o)
+	buf, err := uswid.ToCBORCompression(o.Compression)
+	return writeAll(w, buf, err)
+}
+
+type uswidCodec struct{}
+
+func (uswidCodec) Name() string         { return "uswid" }
+func (uswidCodec) Extensions() []string { return []string{"uswid"} }
+
+func (uswidCodec) Sniff(head []byte) bool {
+	return bytes.HasPrefix(head, magic)
+}
+
+func (uswidCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	// uSWID files hold the blobs only, there is no FMAP to find a region in
+	var img ImageOptions
+	opts.Option(&img)
+	img.Region = ""
+	opts.Options = append([]interface{}{img}, opts.Options...)
+	return imageCodec{}.Decode(r, opts)
+}
+
+func (uswidCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	var o USWIDOptions
+	opts.Option(&o)
+	buf, err := uswid.ToUSWIDOptions(o)
+	return writeAll(w, buf, err)
+}
+
+// imageCodec searches binaries (firmware images, executables, ...) for uSWID
+// data.
+type imageCodec struct{}
+
+func (imageCodec) Name() string { return "image" }
+func (imageCodec) Extensions() []string {
+	return []string{"rom", "bin", "img", "fd", "cap", "efi", "elf"}
+}
+
+func (imageCodec) Sniff(head []byte) bool {
+	return isBinaryImage(head)
+}
+
+func (imageCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	var uswid UswidSoftwareIdentity
+	ra, size, err := readerAt(r)
+	if err != nil {
+		return uswid, err
+	}
+	var img ImageOptions
+	opts.Option(&img)
+	err = uswid.fromImage(ra, size, img)
+	return uswid, err
+}
+
+func (imageCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	return ErrUnsupported
+}
+
+// compressedCodec decompresses files and decodes them in the format detected
+// from the decompressed content.
+type compressedCodec struct {
+	name  string
+	ext   string
+	magic []byte
+}
+
+func (c compressedCodec) Name() string         { return c.name }
+func (c compressedCodec) Extensions() []string { return []string{c.ext} }
+
+func (c compressedCodec) Sniff(head []byte) bool {
+	return bytes.HasPrefix(head, c.magic)
+}
+
+func (c compressedCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	var uswid UswidSoftwareIdentity
+	data, err := decompressFile(r, c.name)
+	if err != nil {
+		return uswid, err
+	}
+	opts.Format = ""
+	err = uswid.Decode(bytes.NewReader(data), opts)
+	return uswid, err
+}
+
+func (c compressedCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	return ErrUnsupported
+}
+
+type plantUMLCodec struct{}
+
+func (plantUMLCodec) Name() string         { return "plantuml" }
+func (plantUMLCodec) Extensions() []string { return []string{"plantuml", "puml"} }
+func (plantUMLCodec) Sniff(head []byte) bool {
+	return false
+}
+
+func (plantUMLCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	return UswidSoftwareIdentity{}, ErrUnsupported
+}
+
+func (plantUMLCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	buf, err := uswid.ToPlantUML()
+	return writeAll(w, buf, err)
+}
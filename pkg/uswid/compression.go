@@ -0,0 +1,146 @@
+package uswid
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// Compression is the compression type of the uSWID payload. Starting with
+// header version 3 it is stored as a separate byte right after the flags.
+type Compression uint8
+
+const (
+	CompressionNone Compression = 0x00
+	CompressionZlib Compression = 0x01
+	CompressionLZMA Compression = 0x02
+)
+
+var compressionNames = map[Compression]string{
+	CompressionNone: "none",
+	CompressionZlib: "zlib",
+	CompressionLZMA: "lzma",
+}
+
+func (c Compression) String() string {
+	if name, ok := compressionNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("compression(%d)", uint8(c))
+}
+
+// ParseCompression returns the Compression for the given name (none, zlib or
+// lzma).
+func ParseCompression(name string) (Compression, error) {
+	for c, n := range compressionNames {
+		if n == name {
+			return c, nil
+		}
+	}
+	return CompressionNone, fmt.Errorf("unknown compression %q", name)
+}
+
+// xz stream header magic, used to tell xz streams apart from legacy
+// .lzma (LZMA alone) streams
+var xzMagic = []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}
+
+// maxLZMADictCap limits the dictionary size of legacy .lzma streams. The
+// reader allocates the dictionary size stored in the stream header up front,
+// so a crafted header could make us allocate up to 4 GiB. 64 MiB is the
+// dictionary size of the highest xz/lzma preset.
+const maxLZMADictCap = 64 << 20
+
+func compress(data []byte, compression Compression) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionZlib:
+		w = zlib.NewWriter(&buf)
+	case CompressionLZMA:
+		// python-uswid uses lzma.compress(), which writes xz streams
+		w, err = xz.NewWriter(&buf)
+		if err != nil {
+			return nil, fmt.Errorf("create xz writer: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown compression type %d", compression)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("cannot %s compress CBOR data: %w", compression, err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("cannot %s compress CBOR data: %w", compression, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressReader(data []byte, compression Compression) (io.Reader, error) {
+	buf := bytes.NewBuffer(data)
+	switch compression {
+	case CompressionNone:
+		return buf, nil
+	case CompressionZlib:
+		rd, err := zlib.NewReader(buf)
+		if err != nil {
+			return nil, fmt.Errorf("create zlib reader: %w", err)
+		}
+		return rd, nil
+	case CompressionLZMA:
+		if bytes.HasPrefix(data, xzMagic) {
+			rd, err := xz.NewReader(buf)
+			if err != nil {
+				return nil, fmt.Errorf("create xz reader: %w", err)
+			}
+			return rd, nil
+		}
+		if len(data) >= lzma.HeaderLen && binary.LittleEndian.Uint32(data[1:5]) > maxLZMADictCap {
+			return nil, fmt.Errorf("lzma dictionary size %d too large", binary.LittleEndian.Uint32(data[1:5]))
+		}
+		rd, err := lzma.NewReader(buf)
+		if err != nil {
+			return nil, fmt.Errorf("create lzma reader: %w", err)
+		}
+		return rd, nil
+	default:
+		return nil, fmt.Errorf("unknown compression type %d", compression)
+	}
+}
+
+// maxDecompressedFileSize limits the size of decompressed input files, which
+// are kept in memory as a whole.
+const maxDecompressedFileSize = 1 << 30
+
+// decompressFile decompresses a gzip or xz compressed input file.
+func decompressFile(r io.Reader, format string) ([]byte, error) {
+	var rd io.Reader
+	var err error
+	switch format {
+	case "gzip":
+		rd, err = gzip.NewReader(r)
+	case "xz":
+		rd, err = xz.NewReader(r)
+	default:
+		return nil, fmt.Errorf("%s is no compressed format", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s reader: %w", format, err)
+	}
+	var out bytes.Buffer
+	n, err := io.Copy(&out, io.LimitReader(rd, maxDecompressedFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing %s: %w", format, err)
+	}
+	if n > maxDecompressedFileSize {
+		return nil, fmt.Errorf("decompressed %s data exceeds %d bytes", format, maxDecompressedFileSize)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,613 @@
+package uswid
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CodingVoid/swid"
+	"github.com/google/uuid"
+)
+
+// CycloneDXNamespace is the XML namespace of CycloneDX 1.5 documents.
+const CycloneDXNamespace = "http://cyclonedx.org/schema/bom/1.5"
+
+// CycloneDX 1.5 BOM, only the fields goswid fills in or reads. The same
+// types are used for JSON and XML.
+type cdxBOM struct {
+	XMLName      xml.Name        `json:"-" xml:"http://cyclonedx.org/schema/bom/1.5 bom"`
+	BOMFormat    string          `json:"bomFormat" xml:"-"`
+	SpecVersion  string          `json:"specVersion" xml:"-"`
+	SerialNumber string          `json:"serialNumber" xml:"serialNumber,attr"`
+	Version      int             `json:"version" xml:"version,attr"`
+	Metadata     cdxMetadata     `json:"metadata" xml:"metadata"`
+	Components   cdxComponents   `json:"components,omitempty" xml:"components,omitempty"`
+	Dependencies cdxDependencies `json:"dependencies,omitempty" xml:"dependencies,omitempty"`
+}
+
+type cdxMetadata struct {
+	Timestamp string        `json:"timestamp" xml:"timestamp"`
+	Tools     cdxTools      `json:"tools" xml:"tools"`
+	Authors   cdxAuthors    `json:"authors,omitempty" xml:"authors,omitempty"`
+	Component *cdxComponent `json:"component,omitempty" xml:"component,omitempty"`
+	// only read, goswid puts the entities into the components
+	Manufacture *cdxEntity `json:"manufacture,omitempty" xml:"manufacture,omitempty"`
+	Supplier    *cdxEntity `json:"supplier,omitempty" xml:"supplier,omitempty"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components" xml:"components>component"`
+}
+
+type cdxComponent struct {
+	Type        string          `json:"type" xml:"type,attr"`
+	BOMRef      string          `json:"bom-ref,omitempty" xml:"bom-ref,attr,omitempty"`
+	Supplier    *cdxEntity      `json:"supplier,omitempty" xml:"supplier,omitempty"`
+	Author      string          `json:"author,omitempty" xml:"author,omitempty"`
+	Group       string          `json:"group,omitempty" xml:"group,omitempty"`
+	Name        string          `json:"name" xml:"name"`
+	Version     string          `json:"version,omitempty" xml:"version,omitempty"`
+	Description string          `json:"description,omitempty" xml:"description,omitempty"`
+	Hashes      cdxHashes       `json:"hashes,omitempty" xml:"hashes,omitempty"`
+	Licenses    cdxLicenses     `json:"licenses,omitempty" xml:"licenses,omitempty"`
+	CPE         string          `json:"cpe,omitempty" xml:"cpe,omitempty"`
+	PURL        string          `json:"purl,omitempty" xml:"purl,omitempty"`
+	SWID        *cdxSWID        `json:"swid,omitempty" xml:"swid,omitempty"`
+	ExternalRef cdxExternalRefs `json:"externalReferences,omitempty" xml:"externalReferences,omitempty"`
+	// only read, goswid doesn't nest components
+	Components cdxComponents `json:"components,omitempty" xml:"components,omitempty"`
+}
+
+// cdxComponents is a list of components. Like for cdxHashes, the wrapping
+// components element of an empty list is only omitted by a custom type.
+type cdxComponents []cdxComponent
+
+type cdxXMLComponents struct {
+	Components []cdxComponent `xml:"component"`
+}
+
+func (c cdxComponents) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(cdxXMLComponents{c}, start)
+}
+
+func (c *cdxComponents) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var list cdxXMLComponents
+	if err := d.DecodeElement(&list, &start); err != nil {
+		return err
+	}
+	*c = append(*c, list.Components...)
+	return nil
+}
+
+type cdxEntity struct {
+	Name string   `json:"name" xml:"name"`
+	URL  []string `json:"url,omitempty" xml:"url,omitempty"`
+}
+
+// cdxAuthors is the list of authors of the BOM, see cdxComponents.
+type cdxAuthors []cdxEntity
+
+type cdxXMLAuthors struct {
+	Authors []cdxEntity `xml:"author"`
+}
+
+func (a cdxAuthors) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(cdxXMLAuthors{a}, start)
+}
+
+func (a *cdxAuthors) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var list cdxXMLAuthors
+	if err := d.DecodeElement(&list, &start); err != nil {
+		return err
+	}
+	*a = append(*a, list.Authors...)
+	return nil
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg" xml:"alg,attr"`
+	Content string `json:"content" xml:",chardata"`
+}
+
+// cdxHashes is a list of hashes, encoding/xml can't omit the hashes element
+// of an empty list on its own.
+type cdxHashes []cdxHash
+
+func (h cdxHashes) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		Hashes []cdxHash `xml:"hash"`
+	}{h}, start)
+}
+
+type cdxLicense struct {
+	ID   string `json:"id,omitempty" xml:"id,omitempty"`
+	Name string `json:"name,omitempty" xml:"name,omitempty"`
+	URL  string `json:"url,omitempty" xml:"url,omitempty"`
+	// Expression is an SPDX license expression, which is a license choice
+	// of its own
+	Expression string `json:"-" xml:"-"`
+}
+
+// cdxLicenses is a list of license choices, each license is wrapped in an
+// object in JSON.
+type cdxLicenses []cdxLicense
+
+type cdxLicenseChoices struct {
+	Licenses    []cdxLicense `xml:"license"`
+	Expressions []string     `xml:"expression"`
+}
+
+type cdxLicenseChoice struct {
+	License    *cdxLicense `json:"license,omitempty"`
+	Expression string      `json:"expression,omitempty"`
+}
+
+func (l cdxLicenses) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var choices cdxLicenseChoices
+	for _, license := range l {
+		if license.Expression != "" {
+			choices.Expressions = append(choices.Expressions, license.Expression)
+		} else {
+			choices.Licenses = append(choices.Licenses, license)
+		}
+	}
+	return e.EncodeElement(choices, start)
+}
+
+func (l *cdxLicenses) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var choices cdxLicenseChoices
+	if err := d.DecodeElement(&choices, &start); err != nil {
+		return err
+	}
+	*l = append(*l, choices.Licenses...)
+	for _, expression := range choices.Expressions {
+		*l = append(*l, cdxLicense{Expression: expression})
+	}
+	return nil
+}
+
+func (l cdxLicenses) MarshalJSON() ([]byte, error) {
+	choices := make([]cdxLicenseChoice, len(l))
+	for i := range l {
+		if l[i].Expression != "" {
+			choices[i].Expression = l[i].Expression
+		} else {
+			choices[i].License = &l[i]
+		}
+	}
+	return json.Marshal(choices)
+}
+
+func (l *cdxLicenses) UnmarshalJSON(data []byte) error {
+	var choices []cdxLicenseChoice
+	if err := json.Unmarshal(data, &choices); err != nil {
+		return err
+	}
+	for _, choice := range choices {
+		if choice.License != nil {
+			*l = append(*l, *choice.License)
+		} else if choice.Expression != "" {
+			*l = append(*l, cdxLicense{Expression: choice.Expression})
+		}
+	}
+	return nil
+}
+
+type cdxSWID struct {
+	TagID      string `json:"tagId" xml:"tagId,attr"`
+	Name       string `json:"name" xml:"name,attr"`
+	Version    string `json:"version,omitempty" xml:"version,attr,omitempty"`
+	TagVersion int    `json:"tagVersion,omitempty" xml:"tagVersion,attr,omitempty"`
+	Patch      bool   `json:"patch,omitempty" xml:"patch,attr,omitempty"`
+}
+
+type cdxExternalRef struct {
+	Type string `json:"type" xml:"type,attr"`
+	URL  string `json:"url" xml:"url"`
+}
+
+type cdxExternalRefs []cdxExternalRef
+
+func (r cdxExternalRefs) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(struct {
+		References []cdxExternalRef `xml:"reference"`
+	}{r}, start)
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// cdxDependencies is the dependency graph, which nests dependency elements
+// in XML.
+type cdxDependencies []cdxDependency
+
+type cdxXMLRef struct {
+	Ref string `xml:"ref,attr"`
+}
+
+type cdxXMLDependency struct {
+	Ref       string      `xml:"ref,attr"`
+	DependsOn []cdxXMLRef `xml:"dependency"`
+}
+
+type cdxXMLDependencies struct {
+	Dependencies []cdxXMLDependency `xml:"dependency"`
+}
+
+func (d cdxDependencies) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	deps := make([]cdxXMLDependency, len(d))
+	for i, dep := range d {
+		deps[i].Ref = dep.Ref
+		for _, r := range dep.DependsOn {
+			deps[i].DependsOn = append(deps[i].DependsOn, cdxXMLRef{r})
+		}
+	}
+	return e.EncodeElement(cdxXMLDependencies{deps}, start)
+}
+
+func (d *cdxDependencies) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var deps cdxXMLDependencies
+	if err := dec.DecodeElement(&deps, &start); err != nil {
+		return err
+	}
+	for _, dep := range deps.Dependencies {
+		c := cdxDependency{Ref: dep.Ref}
+		for _, r := range dep.DependsOn {
+			c.DependsOn = append(c.DependsOn, r.Ref)
+		}
+		*d = append(*d, c)
+	}
+	return nil
+}
+
+// CycloneDX names of the CoSWID hash algorithms, truncated SHA-256 hashes
+// have none
+var cdxHashAlgorithms = map[uint64]string{
+	swid.Sha256:   "SHA-256",
+	swid.Sha384:   "SHA-384",
+	swid.Sha512:   "SHA-512",
+	swid.Sha3_256: "SHA3-256",
+	swid.Sha3_384: "SHA3-384",
+	swid.Sha3_512: "SHA3-512",
+}
+
+// cpeEscape quotes the special characters of a CPE 2.3 formatted string
+// component.
+func cpeEscape(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.ReplaceAll(s, " ", "_")) {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// cdxIdentifiers returns the purl and CPE of id. They are taken from the
+// persistent-id of the software meta entries if it holds one, otherwise a CPE
+// is derived from the software creator, name and version.
+func cdxIdentifiers(id swid.SoftwareIdentity) (purl string, cpe string) {
+	if id.SoftwareMetas != nil {
+		for _, m := range *id.SoftwareMetas {
+			switch {
+			case strings.HasPrefix(m.PersistentID, "pkg:"):
+				purl = m.PersistentID
+			case strings.HasPrefix(m.PersistentID, "cpe:"):
+				cpe = m.PersistentID
+			}
+		}
+	}
+	if creator := entityWithRole(id, "softwareCreator"); cpe == "" && creator != nil && id.SoftwareName != "" && id.SoftwareVersion != "" {
+		cpe = fmt.Sprintf("cpe:2.3:a:%s:%s:%s:*:*:*:*:*:*:*",
+			cpeEscape(creator.EntityName), cpeEscape(id.SoftwareName), cpeEscape(id.SoftwareVersion))
+	}
+	return purl, cpe
+}
+
+// cdxLicenseOf returns the license a license link points to. The id has to
+// be an SPDX license identifier, a "+" following an identifier which isn't
+// one of its own makes an expression. Anything else is a named license.
+func cdxLicenseOf(href string) cdxLicense {
+	if id, ok := spdxLicense(href); ok {
+		if _, known := knownSPDXLicenses[strings.ToLower(id)]; !known && strings.HasSuffix(id, "+") {
+			return cdxLicense{Expression: id}
+		}
+		return cdxLicense{ID: id}
+	}
+	license := cdxLicense{Name: href}
+	if u, err := url.Parse(href); err == nil && u.Scheme != "" {
+		license.URL = href
+	}
+	return license
+}
+
+// toCycloneDX maps the identities to a CycloneDX BOM. The first identity
+// which isn't required by any other becomes the metadata component of type
+// firmware, the dependency graph is built from the requires links.
+func (uswid UswidSoftwareIdentity) toCycloneDX() cdxBOM {
+	created := creationTime()
+	var fingerprint strings.Builder
+	components := make([]cdxComponent, len(uswid.Identities))
+	refs := map[string]bool{}
+	for i, id := range uswid.Identities {
+		fingerprint.WriteString(id.TagID.String() + "\x00" + id.SoftwareVersion + "\x00")
+		c := cdxComponent{
+			Type:        "application",
+			BOMRef:      id.TagID.String(),
+			Name:        id.SoftwareName,
+			Version:     id.SoftwareVersion,
+			Description: softwareMeta(id, func(m swid.SoftwareMeta) string { return m.Summary }),
+			SWID: &cdxSWID{
+				TagID:      id.TagID.String(),
+				Name:       id.SoftwareName,
+				Version:    id.SoftwareVersion,
+				TagVersion: id.TagVersion,
+				Patch:      id.Patch,
+			},
+		}
+		// bom-refs have to be unique
+		for n := 2; refs[c.BOMRef]; n++ {
+			c.BOMRef = fmt.Sprintf("%s-%d", id.TagID.String(), n)
+		}
+		refs[c.BOMRef] = true
+		if c.Description == "" {
+			c.Description = softwareMeta(id, func(m swid.SoftwareMeta) string { return m.Description })
+		}
+		if supplier := entityWithRole(id, "distributor", "softwareCreator", "maintainer"); supplier != nil {
+			c.Supplier = &cdxEntity{Name: supplier.EntityName}
+			if u, err := url.Parse(supplier.RegID); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				c.Supplier.URL = []string{supplier.RegID}
+			}
+		}
+		if author := entityWithRole(id, "softwareCreator"); author != nil {
+			c.Author = author.EntityName
+		}
+		c.PURL, c.CPE = cdxIdentifiers(id)
+		for _, href := range licenseHrefs(id) {
+			c.Licenses = append(c.Licenses, cdxLicenseOf(href))
+		}
+		seen := map[cdxHash]bool{}
+		for _, f := range payloadFiles(id) {
+			if f.Hash == nil {
+				continue
+			}
+			alg, ok := cdxHashAlgorithms[f.Hash.HashAlgID]
+			if !ok {
+				continue
+			}
+			h := cdxHash{Alg: alg, Content: hex.EncodeToString(f.Hash.HashValue)}
+			if !seen[h] {
+				seen[h] = true
+				c.Hashes = append(c.Hashes, h)
+			}
+		}
+		components[i] = c
+	}
+
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, []byte(fingerprint.String())).String(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: created.Format(time.RFC3339),
+			Tools: cdxTools{Components: []cdxComponent{{
+				Type: "application",
+				Name: "goswid",
+				ExternalRef: cdxExternalRefs{{
+					Type: "vcs",
+					URL:  "https://github.com/9elements/goswid",
+				}},
+			}}},
+		},
+	}
+	root := -1
+	if roots := uswid.rootIdentities(); len(roots) > 0 {
+		root = roots[0]
+		components[root].Type = "firmware"
+		bom.Metadata.Component = &components[root]
+	}
+	for i := range components {
+		if i != root {
+			bom.Components = append(bom.Components, components[i])
+		}
+	}
+	for i, id := range uswid.Identities {
+		dep := cdxDependency{Ref: components[i].BOMRef}
+		for _, l := range links(id) {
+			if !hasRel(l, swid.RelRequires) {
+				continue
+			}
+			if j := uswid.identityByHref(l.Href); j != -1 && j != i {
+				dep.DependsOn = append(dep.DependsOn, components[j].BOMRef)
+			}
+		}
+		bom.Dependencies = append(bom.Dependencies, dep)
+	}
+	return bom
+}
+
+// fromCycloneDX maps the components of a CycloneDX BOM to identities. The
+// component the BOM describes comes first, nested components are included.
+// Tag-ids are taken from the swid of the components or derived from their
+// purl, cpe or group, name and version. Components which share the latter
+// are told apart by the serial number of the BOM and their bom-ref.
+func fromCycloneDX(bom cdxBOM) (UswidSoftwareIdentity, error) {
+	var components []cdxComponent
+	var flatten func(list []cdxComponent)
+	flatten = func(list []cdxComponent) {
+		for _, c := range list {
+			components = append(components, c)
+			flatten(c.Components)
+		}
+	}
+	if bom.Metadata.Component != nil {
+		flatten([]cdxComponent{*bom.Metadata.Component})
+	}
+	flatten(bom.Components)
+
+	var tagCreator string
+	switch {
+	case len(bom.Metadata.Authors) > 0:
+		tagCreator = bom.Metadata.Authors[0].Name
+	case bom.Metadata.Manufacture != nil:
+		tagCreator = bom.Metadata.Manufacture.Name
+	case bom.Metadata.Supplier != nil:
+		tagCreator = bom.Metadata.Supplier.Name
+	}
+
+	dependsOn := map[string][]string{}
+	for _, dep := range bom.Dependencies {
+		dependsOn[dep.Ref] = append(dependsOn[dep.Ref], dep.DependsOn...)
+	}
+
+	keys := make([]string, len(components))
+	count := map[string]int{}
+	for i, c := range components {
+		switch {
+		case c.PURL != "":
+			keys[i] = c.PURL
+		case c.CPE != "":
+			keys[i] = c.CPE
+		default:
+			keys[i] = c.Name + "@" + c.Version
+			if c.Group != "" {
+				keys[i] = c.Group + "/" + keys[i]
+			}
+		}
+		count[keys[i]]++
+	}
+	for i, c := range components {
+		if count[keys[i]] == 1 {
+			continue
+		}
+		if c.BOMRef != "" {
+			keys[i] = bom.SerialNumber + "#" + c.BOMRef
+		} else {
+			keys[i] = fmt.Sprintf("%s#%d", keys[i], i)
+		}
+	}
+
+	list := make([]sbomComponent, len(components))
+	for i, c := range components {
+		sc := sbomComponent{
+			ref:         c.BOMRef,
+			key:         keys[i],
+			name:        c.Name,
+			version:     c.Version,
+			description: c.Description,
+			author:      c.Author,
+			requires:    dependsOn[c.BOMRef],
+		}
+		if c.SWID != nil {
+			sc.tagID = c.SWID.TagID
+		}
+		if c.Supplier != nil {
+			sc.supplier = c.Supplier.Name
+			if len(c.Supplier.URL) > 0 {
+				sc.supplierURL = c.Supplier.URL[0]
+			}
+		}
+		for _, l := range c.Licenses {
+			switch {
+			case l.ID != "":
+				sc.licenses = append(sc.licenses, spdxLicenseHref(l.ID))
+			case l.URL != "":
+				sc.licenses = append(sc.licenses, l.URL)
+			case l.Name != "":
+				sc.licenses = append(sc.licenses, l.Name)
+			}
+			for _, id := range licenseExpressionIDs(l.Expression) {
+				sc.licenses = append(sc.licenses, spdxLicenseHref(id))
+			}
+		}
+		list[i] = sc
+	}
+	return fromSBOM(tagCreator, list)
+}
+
+// ToCycloneDXJSON exports the identities as CycloneDX 1.5 JSON document.
+func (uswid UswidSoftwareIdentity) ToCycloneDXJSON() ([]byte, error) {
+	buf, err := json.MarshalIndent(uswid.toCycloneDX(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("convert to CycloneDX: %w", err)
+	}
+	return append(buf, '\n'), nil
+}
+
+// ToCycloneDXXML exports the identities as CycloneDX 1.5 XML document.
+func (uswid UswidSoftwareIdentity) ToCycloneDXXML() ([]byte, error) {
+	buf, err := xml.MarshalIndent(uswid.toCycloneDX(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("convert to CycloneDX: %w", err)
+	}
+	return append(append([]byte(xml.Header), buf...), '\n'), nil
+}
+
+type cycloneDXJSONCodec struct{}
+
+func (cycloneDXJSONCodec) Name() string         { return "cyclonedx-json" }
+func (cycloneDXJSONCodec) Extensions() []string { return []string{"cdx.json"} }
+func (cycloneDXJSONCodec) Sniff(head []byte) bool {
+	text, ok := textHead(head)
+	return ok && text[0] == '{' && bytes.Contains(text, []byte(`"bomFormat"`))
+}
+
+func (cycloneDXJSONCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	var bom cdxBOM
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return UswidSoftwareIdentity{}, err
+	}
+	if err := json.Unmarshal(bytes.TrimPrefix(data, utf8BOM), &bom); err != nil {
+		return UswidSoftwareIdentity{}, fmt.Errorf("parse CycloneDX: %w", err)
+	}
+	if bom.BOMFormat != "CycloneDX" {
+		return UswidSoftwareIdentity{}, fmt.Errorf("parse CycloneDX: unknown bomFormat %q", bom.BOMFormat)
+	}
+	return fromCycloneDX(bom)
+}
+
+func (cycloneDXJSONCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	buf, err := uswid.ToCycloneDXJSON()
+	return writeAll(w, buf, err)
+}
+
+type cycloneDXXMLCodec struct{}
+
+func (cycloneDXXMLCodec) Name() string         { return "cyclonedx-xml" }
+func (cycloneDXXMLCodec) Extensions() []string { return []string{"cdx.xml"} }
+func (cycloneDXXMLCodec) Sniff(head []byte) bool {
+	text, ok := textHead(head)
+	return ok && text[0] == '<' && bytes.Contains(text, []byte("http://cyclonedx.org/schema/bom/"))
+}
+
+func (cycloneDXXMLCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	// accept the namespaces of all CycloneDX versions
+	var bom struct {
+		XMLName xml.Name `xml:"bom"`
+		cdxBOM
+	}
+	if err := xml.NewDecoder(r).Decode(&bom); err != nil {
+		return UswidSoftwareIdentity{}, fmt.Errorf("parse CycloneDX: %w", err)
+	}
+	if !strings.HasPrefix(bom.XMLName.Space, "http://cyclonedx.org/schema/bom/") {
+		return UswidSoftwareIdentity{}, fmt.Errorf("parse CycloneDX: unknown namespace %q", bom.XMLName.Space)
+	}
+	return fromCycloneDX(bom.cdxBOM)
+}
+
+func (cycloneDXXMLCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	buf, err := uswid.ToCycloneDXXML()
+	return writeAll(w, buf, err)
+}
@@ -0,0 +1,93 @@
+package uswid
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToCycloneDX(t *testing.T) {
+	var u UswidSoftwareIdentity
+	err := u.FromJSON(`[{"tag-id":"foo","software-name":"foo","software-version":"1.0","entity":[{"entity-name":"ACME","role":"tagCreator"}],` +
+		`"link":[{"href":"COPYING","rel":"license"},{"href":"MPL-2.0+","rel":"license"},{"href":"https://spdx.org/licenses/MIT.html","rel":"license"},{"href":"swid:bar","rel":"requires"}]},` +
+		`{"tag-id":"bar","software-name":"bar","entity":[{"entity-name":"ACME","role":"tagCreator"}]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bom := u.toCycloneDX()
+	parent := bom.Metadata.Component
+	if parent == nil || parent.Name != "foo" || parent.Type != "firmware" {
+		t.Fatalf("metadata component = %+v, want firmware foo", parent)
+	}
+	want := cdxLicenses{{Name: "COPYING"}, {Expression: "MPL-2.0+"}, {ID: "MIT"}}
+	if !reflect.DeepEqual(parent.Licenses, want) {
+		t.Errorf("licenses = %+v, want %+v", parent.Licenses, want)
+	}
+	if len(bom.Components) != 1 || bom.Components[0].Type != "application" {
+		t.Errorf("components = %+v, want application bar", bom.Components)
+	}
+
+	out, err := u.ToCycloneDXXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// empty lists leave no wrapping element behind
+	for _, empty := range []string{"<components></components>", "<authors>"} {
+		if strings.Contains(string(out), empty) {
+			t.Errorf("XML contains %s:\n%s", empty, out)
+		}
+	}
+}
+
+func TestFromCycloneDXDuplicateNames(t *testing.T) {
+	bom := cdxBOM{
+		SerialNumber: "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		Metadata:     cdxMetadata{Component: &cdxComponent{Type: "firmware", BOMRef: "fw", Name: "fw", Version: "1"}},
+		Components: cdxComponents{
+			{Type: "library", BOMRef: "a", Name: "libfoo", Version: "1.0"},
+			{Type: "library", BOMRef: "b", Name: "libfoo", Version: "1.0"},
+			{Type: "library", Group: "org.example", Name: "libbar", Version: "1.0"},
+			{Type: "library", Group: "com.example", Name: "libbar", Version: "1.0"},
+		},
+		Dependencies: cdxDependencies{{Ref: "fw", DependsOn: []string{"a", "b"}}},
+	}
+	u, err := fromCycloneDX(bom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagIDs := map[string]bool{}
+	for _, id := range u.Identities {
+		if tagIDs[id.TagID.String()] {
+			t.Errorf("tag-id %s of %s is used twice", id.TagID, id.SoftwareName)
+		}
+		tagIDs[id.TagID.String()] = true
+	}
+	if n := len(links(u.Identities[0])); n != 2 {
+		t.Errorf("parent has %d links, want 2", n)
+	}
+}
+
+func TestFromCycloneDXLicenseExpression(t *testing.T) {
+	bom := `{"bomFormat":"CycloneDX","specVersion":"1.5","metadata":{"component":{"type":"firmware","name":"fw","version":"1",` +
+		`"licenses":[{"expression":"(GPL-2.0+ OR MIT) AND Apache-2.0 WITH LLVM-exception"}]}}}`
+	u, err := cycloneDXJSONCodec{}.Decode(strings.NewReader(bom), CodecOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(u.Identities) != 1 {
+		t.Fatalf("got %d identities, want 1", len(u.Identities))
+	}
+	want := []string{
+		"https://spdx.org/licenses/GPL-2.0+.html",
+		"https://spdx.org/licenses/MIT.html",
+		"https://spdx.org/licenses/Apache-2.0.html",
+	}
+	if got := licenseHrefs(u.Identities[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("licenses = %v, want %v", got, want)
+	}
+	// the or-later marker survives the way back
+	out := u.toCycloneDX()
+	if got := out.Metadata.Component.Licenses; len(got) == 0 || got[0].ID != "GPL-2.0+" {
+		t.Errorf("exported licenses = %+v, want GPL-2.0+ first", got)
+	}
+}
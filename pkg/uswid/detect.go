@@ -0,0 +1,111 @@
+package uswid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/9elements/goswid/pkg/cbfs"
+	"github.com/9elements/goswid/pkg/fmap"
+	"github.com/9elements/goswid/pkg/uefi"
+)
+
+// SniffLen is the number of leading bytes passed to Codec.Sniff at most.
+const SniffLen = 512
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	utf8BOM   = []byte{0xef, 0xbb, 0xbf}
+	// CBOR tag 1398229316 marks CoSWID tags
+	coswidTag = []byte{0xda, 0x53, 0x57, 0x49, 0x44}
+)
+
+// textHead returns head without byte order mark and leading whitespace if it
+// looks like text.
+func textHead(head []byte) ([]byte, bool) {
+	text := bytes.TrimPrefix(head, utf8BOM)
+	text = bytes.TrimLeft(text, " \t\r\n")
+	return text, len(text) > 0 && isText(text)
+}
+
+// isBinaryImage looks for the signatures of the binary formats goswid knows.
+func isBinaryImage(head []byte) bool {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x7fELF")),
+		bytes.HasPrefix(head, []byte("MZ")),
+		bytes.HasPrefix(head, []byte("LARCHIVE")),
+		bytes.HasPrefix(head, []byte(fmap.Signature)):
+		return true
+	case len(head) >= 44 && string(head[40:44]) == "_FVH":
+		return true
+	case len(head) >= 16:
+		var g uefi.GUID
+		copy(g[:], head)
+		if g == uefi.FMPCapsuleGUID || g == uefi.CapsuleGUID {
+			return true
+		}
+	}
+	return len(head) >= 4 && binary.BigEndian.Uint32(head) == cbfs.HeaderMagic
+}
+
+// isText reports whether head looks like UTF-8 text. A rune cut off at the
+// end is fine.
+func isText(head []byte) bool {
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		if r == utf8.RuneError && size == 1 {
+			return len(head) < utf8.UTFMax && !utf8.FullRune(head)
+		}
+		if r < ' ' && r != '\t' && r != '\r' && r != '\n' {
+			return false
+		}
+		head = head[size:]
+	}
+	return true
+}
+
+// isPC looks for the fields goswid reads from pkg-config files.
+func isPC(text []byte) bool {
+	for _, line := range strings.Split(string(text), "\n") {
+		if strings.HasPrefix(line, "Name:") || strings.HasPrefix(line, "Version:") {
+			return true
+		}
+	}
+	return false
+}
+
+// isCBOR reports whether head starts like CoSWID tags: a map (major type 5)
+// with an integer key, an array of such maps (major type 4) or the CoSWID
+// CBOR tag.
+func isCBOR(head []byte) bool {
+	if len(head) == 0 {
+		return false
+	}
+	if bytes.HasPrefix(head, coswidTag) {
+		return true
+	}
+	isMap := func(b []byte) bool {
+		// CoSWID map keys are small integers
+		return len(b) > 1 && b[0]>>5 == 5 && b[1]>>5 <= 1
+	}
+	switch head[0] >> 5 {
+	case 5:
+		return isMap(head)
+	case 4:
+		// skip the array length
+		n := 1
+		switch head[0] & 0x1f {
+		case 24:
+			n = 2
+		case 25:
+			n = 3
+		case 26:
+			n = 5
+		case 27:
+			n = 9
+		}
+		return len(head) > n && isMap(head[n:])
+	}
+	return false
+}
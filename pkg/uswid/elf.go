@@ -0,0 +1,28 @@
+package uswid
+
+import (
+	"errors"
+	"io"
+
+	"github.com/9elements/goswid/pkg/elfsection"
+)
+
+// FromELF decodes the uSWID data stored in the section called name (usually
+// elfsection.DefaultName) of the ELF file r. The section holds either uSWID
+// blobs or plain CoSWID tags. elfsection.ErrNotFound is returned if there is
+// no such section.
+func (uswid *UswidSoftwareIdentity) FromELF(r io.ReaderAt, name string) error {
+	data, err := elfsection.Read(r, name)
+	if err != nil {
+		return err
+	}
+	return uswid.fromSection(data)
+}
+
+// fromSection decodes the content of an executable's SBOM section.
+func (uswid *UswidSoftwareIdentity) fromSection(data []byte) error {
+	if _, err := uswid.FromImage(data); !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return uswid.FromCBORCompression(data, CompressionNone)
+}
@@ -0,0 +1,142 @@
+package uswid
+
+import (
+	"io"
+	"strings"
+
+	"github.com/9elements/goswid/pkg/graph"
+	"github.com/CodingVoid/swid"
+)
+
+// relName returns the rel of l, spelled like in XML, e.g. "see-also".
+func relName(l swid.Link) string {
+	for code, name := range xmlRels {
+		if hasRel(l, code) {
+			return name
+		}
+	}
+	return l.Rel.String()
+}
+
+// vendor returns the name of the entity which made the software of id.
+func vendor(id swid.SoftwareIdentity) string {
+	if e := entityWithRole(id, "softwareCreator", "distributor", "maintainer"); e != nil {
+		return e.EntityName
+	}
+	return ""
+}
+
+// Graph returns the identities as nodes and all their links as edges. Nodes
+// of identities are identified by their tag-id. Links to tags which are not
+// part of uswid point to dangling nodes, links to anything else, like
+// licenses, to external nodes named by the href.
+func (uswid UswidSoftwareIdentity) Graph() *graph.Graph {
+	g := &graph.Graph{}
+	for _, id := range uswid.Identities {
+		g.AddNode(graph.Node{
+			ID:      id.TagID.String(),
+			Kind:    graph.Identity,
+			Name:    id.SoftwareName,
+			Version: id.SoftwareVersion,
+			Vendor:  vendor(id),
+		})
+	}
+	for _, id := range uswid.Identities {
+		for _, l := range links(id) {
+			to := l.Href
+			if j := uswid.identityByHref(l.Href); j != -1 {
+				to = uswid.Identities[j].TagID.String()
+			} else if strings.HasPrefix(l.Href, "swid:") {
+				g.AddNode(graph.Node{ID: to, Kind: graph.Dangling, Name: strings.TrimPrefix(l.Href, "swid:")})
+			} else {
+				g.AddNode(graph.Node{ID: to, Kind: graph.External, Name: l.Href})
+			}
+			g.AddEdge(id.TagID.String(), to, relName(l))
+		}
+	}
+	return g
+}
+
+// ToDOT renders the identities and their links as Graphviz DOT digraph.
+func (uswid UswidSoftwareIdentity) ToDOT() ([]byte, error) {
+	var b strings.Builder
+	err := graph.WriteDOT(&b, uswid.Graph())
+	return []byte(b.String()), err
+}
+
+// ToGraphML renders the identities and their links as GraphML document.
+func (uswid UswidSoftwareIdentity) ToGraphML() ([]byte, error) {
+	var b strings.Builder
+	err := graph.WriteGraphML(&b, uswid.Graph())
+	return []byte(b.String()), err
+}
+
+// ToMermaid renders the identities and their links as Mermaid flowchart
+// with a subgraph per vendor.
+func (uswid UswidSoftwareIdentity) ToMermaid() ([]byte, error) {
+	var b strings.Builder
+	err := graph.WriteMermaid(&b, uswid.Graph())
+	return []byte(b.String()), err
+}
+
+// GraphOptions control the diagrams written by the dot, graphml and mermaid
+// codecs.
+type GraphOptions struct {
+	// Depth limits the diagrams to the links at most Depth steps away from
+	// the root identities, 0 draws all of them.
+	Depth int
+}
+
+type dotCodec struct{}
+
+func (dotCodec) Name() string         { return "dot" }
+func (dotCodec) Extensions() []string { return []string{"dot", "gv"} }
+func (dotCodec) Sniff(head []byte) bool {
+	return false
+}
+
+func (dotCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	return UswidSoftwareIdentity{}, ErrUnsupported
+}
+
+func (dotCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	var o GraphOptions
+	opts.Option(&o)
+	return graph.WriteDOT(w, uswid.Graph().Limit(o.Depth))
+}
+
+type graphMLCodec struct{}
+
+func (graphMLCodec) Name() string         { return "graphml" }
+func (graphMLCodec) Extensions() []string { return []string{"graphml"} }
+func (graphMLCodec) Sniff(head []byte) bool {
+	return false
+}
+
+func (graphMLCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	return UswidSoftwareIdentity{}, ErrUnsupported
+}
+
+func (graphMLCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	var o GraphOptions
+	opts.Option(&o)
+	return graph.WriteGraphML(w, uswid.Graph().Limit(o.Depth))
+}
+
+type mermaidCodec struct{}
+
+func (mermaidCodec) Name() string         { return "mermaid" }
+func (mermaidCodec) Extensions() []string { return []string{"mmd", "mermaid"} }
+func (mermaidCodec) Sniff(head []byte) bool {
+	return false
+}
+
+func (mermaidCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	return UswidSoftwareIdentity{}, ErrUnsupported
+}
+
+func (mermaidCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	var o GraphOptions
+	opts.Option(&o)
+	return graph.WriteMermaid(w, uswid.Graph().Limit(o.Depth))
+}
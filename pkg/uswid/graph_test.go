@@ -0,0 +1,85 @@
+package uswid
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/9elements/goswid/pkg/graph"
+)
+
+func TestGraph(t *testing.T) {
+	var u UswidSoftwareIdentity
+	err := u.FromJSON(`[{"tag-id":"fw","software-name":"fw","entity":[{"entity-name":"ACME","role":["tagCreator","softwareCreator"]}],` +
+		`"link":[{"href":"swid:acbd18db-4cc2-f85c-edef-654fccc4a4d8","rel":"requires"},{"href":"swid:gone","rel":"requires"},{"href":"https://spdx.org/licenses/MIT.html","rel":"license"},{"href":"swid:acbd18db-4cc2-f85c-edef-654fccc4a4d8","rel":11}]},` +
+		`{"tag-id":"acbd18db-4cc2-f85c-edef-654fccc4a4d8","software-name":"lib","software-version":"1.0","entity":[{"entity-name":"ACME","role":"tagCreator"}]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// links to the UUID of a tag point to its node
+	const lib = "acbd18db-4cc2-f85c-edef-654fccc4a4d8"
+	g := u.Graph()
+	kinds := map[string]graph.NodeKind{}
+	for _, n := range g.Nodes {
+		kinds[n.ID] = n.Kind
+	}
+	want := map[string]graph.NodeKind{
+		"fw":                                 graph.Identity,
+		lib:                                  graph.Identity,
+		"swid:gone":                          graph.Dangling,
+		"https://spdx.org/licenses/MIT.html": graph.External,
+	}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("node kinds = %v, want %v", kinds, want)
+	}
+	if fw := g.Node("fw"); fw.Vendor != "ACME" {
+		t.Errorf("vendor of fw = %q, want ACME", fw.Vendor)
+	}
+	wantEdges := []graph.Edge{
+		{From: "fw", To: lib, Rel: "requires"},
+		{From: "fw", To: "swid:gone", Rel: "requires"},
+		{From: "fw", To: "https://spdx.org/licenses/MIT.html", Rel: "license"},
+		{From: "fw", To: lib, Rel: "see-also"},
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("edges = %+v, want %+v", g.Edges, wantEdges)
+	}
+}
+
+func TestMermaidDepth(t *testing.T) {
+	var u UswidSoftwareIdentity
+	err := u.FromJSON(`[{"tag-id":"fw","software-name":"fw","entity":[{"entity-name":"ACME","role":["tagCreator","softwareCreator"]}],"link":[{"href":"lib","rel":"requires"}]},` +
+		`{"tag-id":"lib","software-name":"lib","entity":[{"entity-name":"Example","role":["tagCreator","softwareCreator"]}],"link":[{"href":"zlib","rel":"requires"}]},` +
+		`{"tag-id":"zlib","software-name":"zlib","entity":[{"entity-name":"zlib","role":["tagCreator","softwareCreator"]}]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := CodecByName("mermaid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		depth       int
+		contains    []string
+		notContains []string
+	}{
+		{0, []string{`subgraph v0["ACME"]`, `subgraph v1["Example"]`, `subgraph v2["zlib"]`, `n1 -->|"requires"| n2`}, []string{"links)"}},
+		{1, []string{`subgraph v0["ACME"]`, `subgraph v1["Example"]`, `n1["lib<br/>(+1 links)"]`}, []string{"zlib", "n2"}},
+	} {
+		var b strings.Builder
+		if err := codec.Encode(&b, u, CodecOptions{Options: []interface{}{GraphOptions{Depth: test.depth}}}); err != nil {
+			t.Fatal(err)
+		}
+		out := b.String()
+		for _, s := range test.contains {
+			if !strings.Contains(out, s) {
+				t.Errorf("depth %d: diagram lacks %s:\n%s", test.depth, s, out)
+			}
+		}
+		for _, s := range test.notContains {
+			if strings.Contains(out, s) {
+				t.Errorf("depth %d: diagram contains %s:\n%s", test.depth, s, out)
+			}
+		}
+	}
+}
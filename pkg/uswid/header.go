@@ -0,0 +1,169 @@
+package uswid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// header flags, all other bits are reserved
+const (
+	flagCompressed = 0x01
+	knownFlags     = flagCompressed
+)
+
+// header sizes of the known uSWID header versions. Newer writers may extend
+// the header, the payload always starts after the header size stored in the
+// header.
+const (
+	headerSizeV1 = 23
+	headerSizeV2 = 24
+	headerSizeV3 = 25
+)
+
+// DefaultHeaderVersion is the uSWID header version written by default. It
+// is understood by all uSWID readers, version 3 is only written if asked for
+// or if the payload compression can't be expressed in a version 2 header.
+const DefaultHeaderVersion uint8 = 2
+
+// headerVersionFor returns the default header version for payloads
+// compressed with compression.
+func headerVersionFor(compression Compression) uint8 {
+	if compression != CompressionNone && compression != CompressionZlib {
+		return 3
+	}
+	return DefaultHeaderVersion
+}
+
+// Errors returned when parsing uSWID headers. They are wrapped together with
+// the offset of the offending blob, use errors.Is to check for them.
+var (
+	ErrNotFound             = errors.New("could not find uswid data")
+	ErrUnknownHeaderVersion = errors.New("no known header version")
+	ErrTruncatedHeader      = errors.New("truncated uSWID header")
+	ErrUnknownHeaderSize    = errors.New("unknown uSWID header size")
+	ErrPayloadExceedsBlob   = errors.New("uSWID payload exceeds blob")
+	ErrReservedFlags        = errors.New("reserved uSWID header flags set")
+)
+
+// Header is the parsed header of a uSWID blob.
+type Header struct {
+	Version     uint8
+	Size        uint16 // header size including the magic value
+	PayloadSize uint32
+	Flags       uint8 // always 0 for version 1 headers
+	// Compression is derived from the compressed flag for version 2 headers
+	// and read from the compression byte for version 3 headers.
+	Compression Compression
+}
+
+// NewHeader returns a header of the given version for a payload of
+// payloadSize bytes compressed with compression.
+func NewHeader(version uint8, compression Compression, payloadSize uint32) (Header, error) {
+	h := Header{
+		Version:     version,
+		PayloadSize: payloadSize,
+		Compression: compression,
+	}
+	switch version {
+	case 2:
+		if compression != CompressionNone && compression != CompressionZlib {
+			return h, fmt.Errorf("uSWID header version 2 does not support %s compression", compression)
+		}
+		h.Size = headerSizeV2
+	case 3:
+		h.Size = headerSizeV3
+	default:
+		return h, fmt.Errorf("cannot write uSWID header version %d", version)
+	}
+	if compression != CompressionNone {
+		h.Flags |= flagCompressed
+	}
+	return h, nil
+}
+
+// ReservedFlags returns the flag bits goswid does not know about.
+func (h Header) ReservedFlags() uint8 {
+	return h.Flags &^ knownFlags
+}
+
+func minHeaderSize(version uint8) (int, error) {
+	switch version {
+	case 1:
+		return headerSizeV1, nil
+	case 2:
+		return headerSizeV2, nil
+	case 3:
+		return headerSizeV3, nil
+	default:
+		return 0, fmt.Errorf("%w %d", ErrUnknownHeaderVersion, version)
+	}
+}
+
+// ParseHeader parses the uSWID header at the start of data, which has to
+// begin with the magic value. Extensions of the header by newer writers are
+// skipped according to the header size. If reserved flags are set, the
+// parsed header is returned together with ErrReservedFlags.
+func ParseHeader(data []byte) (Header, error) {
+	var h Header
+	if len(data) < headerSizeV1 {
+		return h, ErrTruncatedHeader
+	}
+	if !bytes.Equal(data[:16], magic) {
+		return h, ErrNotFound
+	}
+	h.Version = data[16]
+	h.Size = binary.LittleEndian.Uint16(data[17:19])
+	h.PayloadSize = binary.LittleEndian.Uint32(data[19:23])
+	minSize, err := minHeaderSize(h.Version)
+	if err != nil {
+		return h, err
+	}
+	if int(h.Size) < minSize {
+		return h, fmt.Errorf("%w %d for header version %d", ErrUnknownHeaderSize, h.Size, h.Version)
+	}
+	if len(data) < int(h.Size) {
+		return h, ErrTruncatedHeader
+	}
+	if h.Version == 1 {
+		// version 1 has no flags byte, so the payload is never compressed
+		return h, nil
+	}
+	h.Flags = data[23]
+	if (h.Flags & flagCompressed) != 0 {
+		if h.Version == 2 {
+			h.Compression = CompressionZlib
+		} else {
+			h.Compression = Compression(data[24])
+		}
+	}
+	if h.ReservedFlags() != 0 {
+		return h, fmt.Errorf("%w: %#02x", ErrReservedFlags, h.ReservedFlags())
+	}
+	return h, nil
+}
+
+// MarshalBinary encodes the header including the magic value. Additional
+// header bytes (if Size is larger than known for the version) are zeroed.
+func (h Header) MarshalBinary() ([]byte, error) {
+	minSize, err := minHeaderSize(h.Version)
+	if err != nil {
+		return nil, err
+	}
+	if int(h.Size) < minSize {
+		return nil, fmt.Errorf("%w %d for header version %d", ErrUnknownHeaderSize, h.Size, h.Version)
+	}
+	header := make([]byte, h.Size)
+	copy(header[:16], magic)                             // magic USWID value
+	header[16] = h.Version                               // header version
+	binary.LittleEndian.PutUint16(header[17:19], h.Size) // header size
+	binary.LittleEndian.PutUint32(header[19:23], h.PayloadSize)
+	if h.Version >= 2 {
+		header[23] = h.Flags
+	}
+	if h.Version >= 3 {
+		header[24] = byte(h.Compression) // compression type
+	}
+	return header, nil
+}
@@ -0,0 +1,197 @@
+package uswid
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/9elements/goswid/pkg/graph"
+	"github.com/CodingVoid/swid"
+)
+
+//go:embed report.html.tmpl
+var reportTemplateText string
+
+var reportTemplate = template.Must(template.New("report").Parse(reportTemplateText))
+
+// report is the data of the HTML report template.
+type report struct {
+	Title      string
+	Created    string
+	Identities []reportIdentity
+	Graph      reportGraph
+}
+
+type reportIdentity struct {
+	Anchor   string
+	TagID    string
+	Name     string
+	Version  string
+	Summary  string
+	Entities []reportEntity
+	Licenses []reportLink
+	Links    []reportLink
+	Files    []reportFile
+}
+
+type reportEntity struct {
+	Name  string
+	RegID string
+	Roles string
+}
+
+type reportLink struct {
+	Rel  string
+	Href string
+	// Name is the license identifier or the name of the identity linked to
+	Name string
+	// Anchor is set for links to identities of the report
+	Anchor string
+}
+
+type reportFile struct {
+	Path      string
+	Size      string
+	Algorithm string
+	Hash      string
+}
+
+// reportGraph is the relationship graph the report script draws, it is
+// embedded as JSON.
+type reportGraph struct {
+	Nodes []reportNode `json:"nodes"`
+	Edges []reportEdge `json:"edges"`
+}
+
+type reportNode struct {
+	ID     string   `json:"id"`
+	Label  []string `json:"label"`
+	Kind   string   `json:"kind"`
+	Anchor string   `json:"anchor,omitempty"`
+}
+
+type reportEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Rel   string `json:"rel"`
+	Line  string `json:"line"`
+	Color string `json:"color"`
+}
+
+// hashAlgorithmName returns a readable name of a CoSWID hash algorithm.
+func hashAlgorithmName(alg uint64) string {
+	if name, ok := cdxHashAlgorithms[alg]; ok {
+		return name
+	}
+	return fmt.Sprintf("algorithm %d", alg)
+}
+
+// toReport collects the data of the HTML report.
+func (uswid UswidSoftwareIdentity) toReport(created time.Time) report {
+	r := report{Title: "goswid SBOM", Created: created.Format(time.RFC3339)}
+	if roots := uswid.rootIdentities(); len(roots) > 0 {
+		root := uswid.Identities[roots[0]]
+		r.Title = strings.TrimSpace(root.SoftwareName + " " + root.SoftwareVersion)
+	}
+	anchor := func(i int) string {
+		return fmt.Sprintf("identity-%d", i)
+	}
+
+	for i, id := range uswid.Identities {
+		ri := reportIdentity{
+			Anchor:  anchor(i),
+			TagID:   id.TagID.String(),
+			Name:    id.SoftwareName,
+			Version: id.SoftwareVersion,
+			Summary: softwareMeta(id, func(m swid.SoftwareMeta) string { return m.Summary }),
+		}
+		for _, e := range id.Entities {
+			ri.Entities = append(ri.Entities, reportEntity{
+				Name:  e.EntityName,
+				RegID: e.RegID,
+				Roles: strings.Join(entityRoles(e), ", "),
+			})
+		}
+		for _, l := range links(id) {
+			link := reportLink{Rel: relName(l), Href: l.Href, Name: l.Href}
+			if hasRel(l, swid.RelLicense) {
+				if license, ok := spdxLicense(l.Href); ok {
+					link.Name = license
+				}
+				ri.Licenses = append(ri.Licenses, link)
+				continue
+			}
+			if j := uswid.identityByHref(l.Href); j != -1 {
+				link.Anchor = anchor(j)
+				link.Name = strings.TrimSpace(uswid.Identities[j].SoftwareName + " " + uswid.Identities[j].SoftwareVersion)
+			}
+			ri.Links = append(ri.Links, link)
+		}
+		for _, f := range payloadFiles(id) {
+			rf := reportFile{Path: f.Path}
+			if f.Size != nil {
+				rf.Size = fmt.Sprint(*f.Size)
+			}
+			if f.Hash != nil {
+				rf.Algorithm = hashAlgorithmName(f.Hash.HashAlgID)
+				rf.Hash = hex.EncodeToString(f.Hash.HashValue)
+			}
+			ri.Files = append(ri.Files, rf)
+		}
+		r.Identities = append(r.Identities, ri)
+	}
+
+	g := uswid.Graph()
+	anchors := map[string]string{}
+	for i, id := range uswid.Identities {
+		if _, ok := anchors[id.TagID.String()]; !ok {
+			anchors[id.TagID.String()] = anchor(i)
+		}
+	}
+	for _, n := range g.Nodes {
+		r.Graph.Nodes = append(r.Graph.Nodes, reportNode{
+			ID:     n.ID,
+			Label:  n.Label(),
+			Kind:   n.Kind.String(),
+			Anchor: anchors[n.ID],
+		})
+	}
+	for _, e := range g.Edges {
+		line, color := graph.Style(e.Rel)
+		r.Graph.Edges = append(r.Graph.Edges, reportEdge{From: e.From, To: e.To, Rel: e.Rel, Line: line, Color: color})
+	}
+	return r
+}
+
+// ToHTML renders the identities as a self-contained HTML report, which lists
+// their entities, licenses, links and payload files and draws the
+// relationship graph. The page loads nothing from the network.
+func (uswid UswidSoftwareIdentity) ToHTML() ([]byte, error) {
+	var b bytes.Buffer
+	if err := reportTemplate.Execute(&b, uswid.toReport(creationTime())); err != nil {
+		return nil, fmt.Errorf("render HTML report: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+type htmlCodec struct{}
+
+func (htmlCodec) Name() string         { return "html" }
+func (htmlCodec) Extensions() []string { return []string{"html", "htm"} }
+func (htmlCodec) Sniff(head []byte) bool {
+	return false
+}
+
+func (htmlCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	return UswidSoftwareIdentity{}, ErrUnsupported
+}
+
+func (htmlCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	buf, err := uswid.ToHTML()
+	return writeAll(w, buf, err)
+}
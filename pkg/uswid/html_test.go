@@ -0,0 +1,114 @@
+package uswid
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const hostileName = `</script><img src=x onerror=alert(1)>`
+
+func hostileIdentities(t *testing.T) UswidSoftwareIdentity {
+	t.Helper()
+	var u UswidSoftwareIdentity
+	err := u.FromJSON(`[{"tag-id":"fw","software-name":"` + hostileName + `","software-version":"1.0 & more","entity":[{"entity-name":"<b>ACME</b>","role":"tagCreator"}],` +
+		`"link":[{"href":"lib","rel":"requires"},{"href":"https://example.com/evil.js","rel":"requires"},` +
+		`{"href":"https://spdx.org/licenses/MIT.html","rel":"license"},{"href":"javascript:alert(1)","rel":"license"}]},` +
+		`{"tag-id":"lib","software-name":"lib</script>","entity":[{"entity-name":"ACME","role":"tagCreator"}]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestToReport(t *testing.T) {
+	u := hostileIdentities(t)
+	r := u.toReport(time.Unix(0, 0).UTC())
+	// the report holds the plain data, the template escapes it
+	if want := hostileName + " 1.0 & more"; r.Title != want {
+		t.Errorf("title = %q, want %q", r.Title, want)
+	}
+	if r.Created != "1970-01-01T00:00:00Z" {
+		t.Errorf("created = %q", r.Created)
+	}
+	if len(r.Identities) != 2 {
+		t.Fatalf("%d identities, want 2", len(r.Identities))
+	}
+	fw := r.Identities[0]
+	if len(fw.Licenses) != 2 || fw.Licenses[0].Name != "MIT" || fw.Licenses[1].Name != "javascript:alert(1)" {
+		t.Errorf("licenses = %+v", fw.Licenses)
+	}
+	if len(fw.Links) != 2 || fw.Links[0].Anchor != "identity-1" || fw.Links[0].Name != "lib</script>" || fw.Links[1].Anchor != "" {
+		t.Errorf("links = %+v", fw.Links)
+	}
+	if n := r.Graph.Nodes[0]; n.ID != "fw" || n.Label[0] != hostileName || n.Anchor != "identity-0" {
+		t.Errorf("first graph node = %+v", n)
+	}
+}
+
+func TestToHTML(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "0")
+	out, err := hostileIdentities(t).ToHTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(out)
+	lower := strings.ToLower(page)
+	if n := strings.Count(lower, "<script"); n != 1 {
+		t.Errorf("page has %d script elements, want 1", n)
+	}
+	if n := strings.Count(lower, "</script"); n != 1 {
+		t.Errorf("page closes %d script elements, want 1", n)
+	}
+	for _, raw := range []string{"<img", "<b>"} {
+		if strings.Contains(lower, raw) {
+			t.Errorf("page contains unescaped %s", raw)
+		}
+	}
+	for _, want := range []string{
+		`<h1>&lt;/script&gt;&lt;img src=x onerror=alert(1)&gt; 1.0 &amp; more</h1>`,
+		`<td>&lt;b&gt;ACME&lt;/b&gt;</td>`,
+		`<code>https://example.com/evil.js</code>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %s", want)
+		}
+	}
+
+	// the graph the script draws still holds the names
+	start := strings.Index(page, "const graph = ")
+	end := strings.Index(page[start:], ";\n")
+	if start == -1 || end == -1 {
+		t.Fatal("page has no graph")
+	}
+	var g reportGraph
+	if err := json.Unmarshal([]byte(page[start+len("const graph = "):start+end]), &g); err != nil {
+		t.Fatalf("graph is no JSON: %v", err)
+	}
+	if len(g.Nodes) == 0 || g.Nodes[0].Label[0] != hostileName {
+		t.Errorf("graph nodes = %+v", g.Nodes)
+	}
+
+	// the page loads nothing, only licenses link outside of it
+	attr := regexp.MustCompile(`(?i)\s(src|href)\s*=\s*["']?([^"'\s>]*)`)
+	var attrs [][]string
+	for _, tag := range regexp.MustCompile(`<[a-zA-Z][^>]*>`).FindAllString(page, -1) {
+		attrs = append(attrs, attr.FindAllStringSubmatch(tag, -1)...)
+	}
+	if len(attrs) == 0 {
+		t.Fatal("page has no links")
+	}
+	for _, a := range attrs {
+		switch value := a[2]; {
+		case strings.HasPrefix(value, "#"):
+		case value == "https://spdx.org/licenses/MIT.html":
+		default:
+			t.Errorf("page refers to %s=%q", a[1], value)
+		}
+	}
+	if !strings.Contains(page, `href="#ZgotmplZ"`) {
+		t.Error("javascript: license link not replaced")
+	}
+}
@@ -0,0 +1,184 @@
+package uswid
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/9elements/goswid/pkg/cbfs"
+	"github.com/9elements/goswid/pkg/elfsection"
+	"github.com/9elements/goswid/pkg/fmap"
+	"github.com/9elements/goswid/pkg/pesection"
+)
+
+// ImageOptions control how the image codec searches binaries.
+type ImageOptions struct {
+	// Region restricts the search to the FMAP region of this name.
+	Region string
+	// Section is the name of the ELF or PE section holding the uSWID data,
+	// ".sbom" if empty.
+	Section string
+	// CBFSFile is the name of the CBFS file holding the uSWID data of
+	// coreboot images, cbfs.DefaultSBOMName if empty.
+	CBFSFile string
+}
+
+// ImageSection returns the part of the first size bytes of r described by
+// region, which is the name of an FMAP region. If region is empty, the whole
+// image is returned. The returned offset is the start of the section in r.
+func ImageSection(r io.ReaderAt, size int64, region string) (*io.SectionReader, int64, error) {
+	if region == "" {
+		return io.NewSectionReader(r, 0, size), 0, nil
+	}
+	m, err := fmap.Find(r, size)
+	if err != nil {
+		return nil, 0, fmt.Errorf("region %s: %w", region, err)
+	}
+	area := m.Area(region)
+	if area == nil {
+		return nil, 0, fmt.Errorf("no FMAP region %s", region)
+	}
+	if area.End() > size {
+		return nil, 0, fmt.Errorf("FMAP region %s exceeds image", region)
+	}
+	return io.NewSectionReader(r, int64(area.Offset), int64(area.Size)), int64(area.Offset), nil
+}
+
+// fromImage decodes the uSWID data of the first size bytes of the binary
+// image r (SPI dumps, disk images, executables ...). These can be huge, so
+// they are not read into memory as a whole.
+func (uswid *UswidSoftwareIdentity) fromImage(r io.ReaderAt, size int64, opts ImageOptions) error {
+	name := opts.Section
+	switch {
+	case elfsection.IsELF(r):
+		// executables have no FMAP
+		opts.Region = ""
+		if name == "" {
+			name = elfsection.DefaultName
+		}
+		err := uswid.FromELF(r, name)
+		if !errors.Is(err, elfsection.ErrNotFound) {
+			return err
+		}
+	case pesection.IsPE(r):
+		opts.Region = ""
+		if name == "" {
+			name = pesection.DefaultName
+		}
+		err := uswid.FromPE(r, name)
+		if !errors.Is(err, pesection.ErrNotFound) {
+			return err
+		}
+	}
+	// executables without SBOM section might still carry it somewhere in
+	// their data
+	section, _, err := ImageSection(r, size, opts.Region)
+	if err != nil {
+		return err
+	}
+
+	// coreboot stores the SBOM as CBFS file, prefer that over searching for
+	// the magic value. If the file can't be decoded, the magic scan might
+	// still find the uSWID data.
+	cbfsFile := opts.CBFSFile
+	if cbfsFile == "" {
+		cbfsFile = cbfs.DefaultSBOMName
+	}
+	var cbfsIDs UswidSoftwareIdentity
+	found, cbfsErr := cbfsIDs.FromCBFS(section, section.Size(), cbfsFile)
+	if cbfsErr == nil && len(found) > 0 {
+		uswid.Identities = append(uswid.Identities, cbfsIDs.Identities...)
+		return nil
+	}
+	// EDK2 based firmware keeps it in FFS files, which might be compressed
+	var uefiIDs UswidSoftwareIdentity
+	ffs, uefiErr := uefiIDs.FromUEFI(section, section.Size())
+	if uefiErr == nil && len(ffs) > 0 {
+		uswid.Identities = append(uswid.Identities, uefiIDs.Identities...)
+		return nil
+	}
+	_, err = uswid.FromReader(section)
+	if errors.Is(err, ErrNotFound) {
+		// tell why the CBFS file or the firmware volumes did not work out
+		if cbfsErr != nil {
+			return cbfsErr
+		}
+		if uefiErr != nil {
+			return fmt.Errorf("UEFI firmware volumes: %w", uefiErr)
+		}
+	}
+	return err
+}
+
+// RegionSBOM is the uSWID data found in one FMAP region of a flash image.
+type RegionSBOM struct {
+	// Region is the name of the innermost FMAP area holding the data, it is
+	// empty for data outside of all areas.
+	Region string
+	UswidSoftwareIdentity
+}
+
+// ImageRegions decodes the uSWID data of the first size bytes of the flash
+// image r like FromFile does, but groups the identities by the FMAP region
+// holding them, so the SBOMs of A/B slots can be told apart. The regions are
+// returned in the order their data appears in the image.
+func ImageRegions(r io.ReaderAt, size int64, opts ImageOptions) ([]RegionSBOM, error) {
+	layout, err := fmap.Find(r, size)
+	if err != nil {
+		return nil, err
+	}
+	section, base, err := ImageSection(r, size, opts.Region)
+	if err != nil {
+		return nil, err
+	}
+	var sboms []RegionSBOM
+	add := func(offset int64, ids UswidSoftwareIdentity) {
+		name := ""
+		if a := layout.AreaAt(base + offset); a != nil {
+			name = a.Name
+		}
+		for i := range sboms {
+			if sboms[i].Region == name {
+				sboms[i].Identities = append(sboms[i].Identities, ids.Identities...)
+				return
+			}
+		}
+		sboms = append(sboms, RegionSBOM{Region: name, UswidSoftwareIdentity: ids})
+	}
+
+	// like fromImage, prefer the CBFS files and fall back to the magic scan
+	// if there are none or they can't be decoded
+	cbfsFile := opts.CBFSFile
+	if cbfsFile == "" {
+		cbfsFile = cbfs.DefaultSBOMName
+	}
+	files, err := cbfs.Parse(section, section.Size())
+	if err == nil {
+		for _, f := range cbfs.Find(files, cbfsFile) {
+			var ids UswidSoftwareIdentity
+			content, err := f.Content()
+			if err == nil {
+				_, err = ids.FromImage(content)
+			}
+			if err != nil {
+				sboms = nil
+				break
+			}
+			add(f.Offset, ids)
+		}
+	}
+	if len(sboms) > 0 {
+		return sboms, nil
+	}
+	s := NewScanner(section)
+	for s.Next() {
+		add(int64(s.Blob().Offset), s.ids)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(sboms) == 0 {
+		return nil, ErrNotFound
+	}
+	return sboms, nil
+}
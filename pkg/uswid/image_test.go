@@ -0,0 +1,143 @@
+package uswid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type fixtureArea struct {
+	name           string
+	offset, size   uint32
+	software, vers string // the identity stored at the start of the area
+}
+
+var fixtureAreas = []fixtureArea{
+	{name: "WP_RO", offset: 0x0000, size: 0x8000},
+	{name: "FMAP", offset: 0x0000, size: 0x1000},
+	{name: "COREBOOT", offset: 0x1000, size: 0x7000, software: "coreboot", vers: "4.22"},
+	{name: "FW_MAIN_A", offset: 0x8000, size: 0x4000, software: "ec", vers: "1.0"},
+	{name: "FW_MAIN_B", offset: 0xc000, size: 0x4000, software: "ec", vers: "1.1"},
+}
+
+// buildFlashImage generates a flash image with an FMAP describing
+// fixtureAreas and a uSWID blob at the start of the areas holding software.
+func buildFlashImage(t *testing.T) []byte {
+	t.Helper()
+	image := bytes.Repeat([]byte{0xff}, 0x10000)
+
+	var m bytes.Buffer
+	m.WriteString("__FMAP__\x01\x01")
+	binary.Write(&m, binary.LittleEndian, uint64(0xff000000))
+	binary.Write(&m, binary.LittleEndian, uint32(len(image)))
+	m.Write(make([]byte, 32))
+	binary.Write(&m, binary.LittleEndian, uint16(len(fixtureAreas)))
+	for _, a := range fixtureAreas {
+		binary.Write(&m, binary.LittleEndian, a.offset)
+		binary.Write(&m, binary.LittleEndian, a.size)
+		name := make([]byte, 32)
+		copy(name, a.name)
+		m.Write(name)
+		m.Write([]byte{0, 0})
+	}
+	copy(image[0x100:], m.Bytes())
+
+	for _, a := range fixtureAreas {
+		if a.software == "" {
+			continue
+		}
+		var u UswidSoftwareIdentity
+		err := u.FromJSON(fmt.Sprintf(`{"tag-id":"%s-%s","software-name":"%s","software-version":"%s","entity":[{"entity-name":"ACME","role":"tagCreator"}]}`,
+			a.software, a.vers, a.software, a.vers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		blob, err := u.ToUSWID(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(image[a.offset:], blob)
+	}
+	return image
+}
+
+func versions(u UswidSoftwareIdentity) []string {
+	var vers []string
+	for _, id := range u.Identities {
+		vers = append(vers, id.SoftwareName+" "+id.SoftwareVersion)
+	}
+	return vers
+}
+
+func TestImageRegions(t *testing.T) {
+	image := buildFlashImage(t)
+	for _, test := range []struct {
+		region string
+		want   map[string][]string
+		order  []string
+	}{
+		{
+			want: map[string][]string{
+				"COREBOOT":  {"coreboot 4.22"},
+				"FW_MAIN_A": {"ec 1.0"},
+				"FW_MAIN_B": {"ec 1.1"},
+			},
+			order: []string{"COREBOOT", "FW_MAIN_A", "FW_MAIN_B"},
+		},
+		{
+			// offsets in the region are mapped back to the image
+			region: "FW_MAIN_B",
+			want:   map[string][]string{"FW_MAIN_B": {"ec 1.1"}},
+			order:  []string{"FW_MAIN_B"},
+		},
+	} {
+		sboms, err := ImageRegions(bytes.NewReader(image), int64(len(image)), ImageOptions{Region: test.region})
+		if err != nil {
+			t.Fatalf("region %q: %v", test.region, err)
+		}
+		var order []string
+		got := map[string][]string{}
+		for _, s := range sboms {
+			order = append(order, s.Region)
+			got[s.Region] = versions(s.UswidSoftwareIdentity)
+		}
+		if !reflect.DeepEqual(order, test.order) || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("region %q: ImageRegions = %v %v, want %v %v", test.region, order, got, test.order, test.want)
+		}
+	}
+
+	if _, err := ImageRegions(bytes.NewReader(image), int64(len(image)), ImageOptions{Region: "RW_LEGACY"}); err == nil {
+		t.Error("ImageRegions of a missing region succeeded")
+	}
+}
+
+func TestDecodeImageRegion(t *testing.T) {
+	image := buildFlashImage(t)
+	for _, test := range []struct {
+		region string
+		want   []string
+	}{
+		{"", []string{"coreboot 4.22", "ec 1.0", "ec 1.1"}},
+		{"FW_MAIN_A", []string{"ec 1.0"}},
+		{"FW_MAIN_B", []string{"ec 1.1"}},
+		{"WP_RO", []string{"coreboot 4.22"}},
+	} {
+		// what the CLI passes for --region
+		opts := CodecOptions{Filename: "image.rom", Options: []interface{}{ImageOptions{Region: test.region}}}
+		var u UswidSoftwareIdentity
+		if err := u.Decode(bytes.NewReader(image), opts); err != nil {
+			t.Fatalf("region %q: %v", test.region, err)
+		}
+		if got := versions(u); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("region %q: identities = %v, want %v", test.region, got, test.want)
+		}
+	}
+
+	var u UswidSoftwareIdentity
+	opts := CodecOptions{Format: "image", Options: []interface{}{ImageOptions{Region: "RW_LEGACY"}}}
+	if err := u.Decode(bytes.NewReader(image), opts); err == nil {
+		t.Errorf("Decode of a missing region succeeded with %v", versions(u))
+	}
+}
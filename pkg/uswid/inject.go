@@ -0,0 +1,178 @@
+package uswid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/9elements/goswid/pkg/cbfs"
+	"github.com/9elements/goswid/pkg/uefi"
+)
+
+// ErrBlobTooLarge is returned if a uSWID blob does not fit into the region it
+// should be written to.
+var ErrBlobTooLarge = errors.New("uSWID blob does not fit into region")
+
+// ErrNotStrippable is returned by Strip if the image holds uSWID data which
+// can't be removed in place, because it is stored compressed in a CBFS file
+// or in a compressed section of an UEFI firmware file. Sections which can't
+// be decoded (e.g. Tiano or Brotli compressed ones) might hold uSWID data as
+// well, so they can't be stripped either.
+var ErrNotStrippable = errors.New("cannot strip compressed uSWID data")
+
+// Region is a part of an image reserved for uSWID data.
+type Region struct {
+	Offset int
+	Size   int
+}
+
+// End returns the offset of the first byte after the region.
+func (r Region) End() int {
+	return r.Offset + r.Size
+}
+
+// FindBlobRegion returns the region taken by the index-th uSWID blob in image.
+// The region ends with the blob: the bytes following it may look like
+// padding (e.g. erased flash), but might as well belong to the next FMAP
+// area or CBFS file.
+func FindBlobRegion(image []byte, index int) (Region, error) {
+	blobs, err := Scan(image)
+	if err != nil {
+		return Region{}, err
+	}
+	if index < 0 || index >= len(blobs) {
+		return Region{}, fmt.Errorf("%w: want blob %d, found %d", ErrNotFound, index, len(blobs))
+	}
+	return Region{Offset: blobs[index].Offset, Size: blobs[index].End() - blobs[index].Offset}, nil
+}
+
+// FindPlaceholder returns the region of image filled with repetitions of
+// pattern, starting at the first occurrence of pattern.
+func FindPlaceholder(image []byte, pattern []byte) (Region, error) {
+	if len(pattern) == 0 {
+		return Region{}, errors.New("empty placeholder pattern")
+	}
+	offset := bytes.Index(image, pattern)
+	if offset == -1 {
+		return Region{}, errors.New("could not find placeholder")
+	}
+	end := offset
+	for bytes.HasPrefix(image[end:], pattern) {
+		end += len(pattern)
+	}
+	return Region{Offset: offset, Size: end - offset}, nil
+}
+
+// Inject writes blob into region r of image and fills the remaining bytes of
+// the region with pad. image is left untouched if blob does not fit.
+func Inject(image []byte, r Region, blob []byte, pad byte) error {
+	if r.Offset < 0 || r.Size < 0 || r.End() > len(image) {
+		return fmt.Errorf("region %#x-%#x outside of image (%d bytes)", r.Offset, r.End(), len(image))
+	}
+	if len(blob) > r.Size {
+		return fmt.Errorf("%w: %d bytes blob, %d bytes region", ErrBlobTooLarge, len(blob), r.Size)
+	}
+	n := copy(image[r.Offset:r.End()], blob)
+	for i := r.Offset + n; i < r.End(); i++ {
+		image[i] = pad
+	}
+	return nil
+}
+
+// StripMode selects how Strip removes uSWID blobs from an image.
+type StripMode int
+
+const (
+	// StripErase overwrites the whole blob with the pad byte.
+	StripErase StripMode = iota
+	// StripTruncate keeps the header but drops the payload, so the image
+	// still shows where uSWID data was.
+	StripTruncate
+)
+
+// Strip removes all uSWID blobs from image in place and returns the regions
+// it touched. The size of the image does not change. Blobs stored in
+// compressed CBFS files or compressed UEFI sections are not visible in the
+// image and can't be stripped in place, ErrNotStrippable is returned if there
+// are any or if there are UEFI sections which can't be decoded. The
+// uncompressed blobs are stripped from image even then.
+func Strip(image []byte, mode StripMode, pad byte) ([]Region, error) {
+	blobs, err := Scan(image)
+	if err != nil {
+		return nil, err
+	}
+	var regions []Region
+	for _, b := range blobs {
+		r := Region{Offset: b.Offset, Size: b.End() - b.Offset}
+		var empty []byte
+		if mode == StripTruncate {
+			h := Header{Version: b.Header.Version, Size: b.Header.Size}
+			if empty, err = h.MarshalBinary(); err != nil {
+				return regions, err
+			}
+		}
+		if err := Inject(image, r, empty, pad); err != nil {
+			return regions, err
+		}
+		regions = append(regions, r)
+	}
+	left, err := compressedBlobs(image)
+	if err != nil {
+		return regions, err
+	}
+	if len(left) > 0 {
+		return regions, fmt.Errorf("%w in %s", ErrNotStrippable, strings.Join(left, ", "))
+	}
+	return regions, nil
+}
+
+// compressedBlobs returns where image holds uSWID data with payload in CBFS
+// files or UEFI firmware files, which the magic scan can't see because they
+// are compressed, and the UEFI sections which can't be decoded to tell.
+func compressedBlobs(image []byte) ([]string, error) {
+	r := bytes.NewReader(image)
+	var left []string
+	files, err := cbfs.Parse(r, r.Size())
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Compression == cbfs.CompressionNone {
+			continue
+		}
+		content, err := f.Content()
+		if err != nil {
+			return nil, fmt.Errorf("CBFS file %q at %#x: %w", f.Name, f.Offset, err)
+		}
+		blobs, _ := Scan(content)
+		for _, b := range blobs {
+			if b.Header.PayloadSize > 0 {
+				left = append(left, fmt.Sprintf("CBFS file %q at %#x", f.Name, f.Offset))
+				break
+			}
+		}
+	}
+
+	volumes, err := uefiVolumes(r, r.Size())
+	if err != nil {
+		return nil, fmt.Errorf("UEFI firmware volumes: %w", err)
+	}
+	err = uefi.Walk(volumes, func(f *uefi.File, data []byte) error {
+		blobs, _ := Scan(data)
+		for _, b := range blobs {
+			if b.Header.PayloadSize > 0 {
+				left = append(left, fmt.Sprintf("FFS file %s", f.Name))
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	uefi.Undecoded(volumes, func(f *uefi.File, s *uefi.Section) {
+		left = append(left, fmt.Sprintf("FFS file %s (%v)", f.Name, s.Err))
+	})
+	return left, nil
+}
@@ -0,0 +1,219 @@
+package uswid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/9elements/goswid/pkg/uefi"
+)
+
+// testBlob returns an uncompressed uSWID blob with an identity called name.
+func testBlob(t *testing.T, name string) []byte {
+	t.Helper()
+	var u UswidSoftwareIdentity
+	err := u.FromJSON(fmt.Sprintf(`{"tag-id":"%s","software-name":"%s","entity":[{"entity-name":"ACME","role":"tagCreator"}]}`, name, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, err := u.ToUSWID(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+func TestInject(t *testing.T) {
+	image := bytes.Repeat([]byte{0xaa}, 64)
+	r := Region{Offset: 8, Size: 16}
+	if err := Inject(image, r, []byte("0123456789"), 0xff); err != nil {
+		t.Fatal(err)
+	}
+	want := append(append(append(bytes.Repeat([]byte{0xaa}, 8), "0123456789"...), bytes.Repeat([]byte{0xff}, 6)...), bytes.Repeat([]byte{0xaa}, 40)...)
+	if !bytes.Equal(image, want) {
+		t.Errorf("image = % x, want % x", image, want)
+	}
+
+	orig := append([]byte{}, image...)
+	if err := Inject(image, r, make([]byte, 17), 0); !errors.Is(err, ErrBlobTooLarge) {
+		t.Errorf("Inject of a too large blob: %v, want %v", err, ErrBlobTooLarge)
+	}
+	for _, r := range []Region{{Offset: 60, Size: 8}, {Offset: -1, Size: 4}, {Offset: 0, Size: -1}} {
+		if err := Inject(image, r, nil, 0); err == nil {
+			t.Errorf("Inject into %+v succeeded", r)
+		}
+	}
+	if !bytes.Equal(image, orig) {
+		t.Error("failed Inject modified the image")
+	}
+}
+
+func TestFindBlobRegion(t *testing.T) {
+	first, second := testBlob(t, "first"), testBlob(t, "second")
+	image := bytes.Repeat([]byte{0xff}, 0x100)
+	copy(image[0x10:], first)
+	copy(image[0x80:], second)
+
+	for i, want := range []Region{{0x10, len(first)}, {0x80, len(second)}} {
+		r, err := FindBlobRegion(image, i)
+		if err != nil {
+			t.Fatalf("blob %d: %v", i, err)
+		}
+		if r != want {
+			t.Errorf("blob %d: region %+v, want %+v", i, r, want)
+		}
+	}
+	for _, index := range []int{-1, 2} {
+		if _, err := FindBlobRegion(image, index); !errors.Is(err, ErrNotFound) {
+			t.Errorf("blob %d: %v, want %v", index, err, ErrNotFound)
+		}
+	}
+
+	// replace the second blob by a smaller one, grown to the end of the image
+	r, err := FindBlobRegion(image, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Size = len(image) - r.Offset
+	if err := Inject(image, r, testBlob(t, "new"), 0xff); err != nil {
+		t.Fatal(err)
+	}
+	var u UswidSoftwareIdentity
+	if _, err := u.FromImage(image); err != nil {
+		t.Fatal(err)
+	}
+	if got := softwareNames(u); !reflect.DeepEqual(got, []string{"first", "new"}) {
+		t.Errorf("software names = %v, want [first new]", got)
+	}
+}
+
+func TestFindPlaceholder(t *testing.T) {
+	pattern := []byte("SBOM")
+	image := []byte("code SBO SBOMSBOMSBOMSB more code SBOMSBOM")
+	r, err := FindPlaceholder(image, pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a partial repetition is not part of the region, a later run is ignored
+	if want := (Region{Offset: 9, Size: 12}); r != want {
+		t.Errorf("region %+v, want %+v", r, want)
+	}
+
+	if err := Inject(image, r, []byte("blob"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := "code SBO blob\x00\x00\x00\x00\x00\x00\x00\x00SB more code SBOMSBOM"; string(image) != want {
+		t.Errorf("image = %q, want %q", image, want)
+	}
+
+	if _, err := FindPlaceholder(image, []byte("FREE")); err == nil {
+		t.Error("FindPlaceholder of a missing pattern succeeded")
+	}
+	if _, err := FindPlaceholder(image, nil); err == nil {
+		t.Error("FindPlaceholder of an empty pattern succeeded")
+	}
+}
+
+func TestStrip(t *testing.T) {
+	first, second := testBlob(t, "first"), testBlob(t, "second")
+	build := func() []byte {
+		image := bytes.Repeat([]byte{0xaa}, 0x100)
+		copy(image[0x10:], first)
+		copy(image[0x80:], second)
+		return image
+	}
+	want := []Region{{0x10, len(first)}, {0x80, len(second)}}
+
+	image := build()
+	regions, err := Strip(image, StripErase, 0xff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(regions, want) {
+		t.Errorf("erase: regions = %+v, want %+v", regions, want)
+	}
+	for _, r := range want {
+		if !bytes.Equal(image[r.Offset:r.End()], bytes.Repeat([]byte{0xff}, r.Size)) {
+			t.Errorf("erase: region %+v not padded: % x", r, image[r.Offset:r.End()])
+		}
+	}
+	if image[0x0f] != 0xaa || image[want[0].End()] != 0xaa {
+		t.Error("erase: touched bytes outside of the blobs")
+	}
+	if blobs, err := Scan(image); err != nil || len(blobs) != 0 {
+		t.Errorf("erase: Scan = %+v, %v, want no blobs", blobs, err)
+	}
+
+	image = build()
+	regions, err = Strip(image, StripTruncate, 0xff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(regions, want) {
+		t.Errorf("truncate: regions = %+v, want %+v", regions, want)
+	}
+	blobs, err := Scan(image)
+	if err != nil {
+		t.Fatalf("truncate: Scan: %v", err)
+	}
+	if len(blobs) != 2 {
+		t.Fatalf("truncate: found %d blobs, want 2", len(blobs))
+	}
+	for i, b := range blobs {
+		if b.Offset != want[i].Offset || b.Header.PayloadSize != 0 {
+			t.Errorf("truncate: blob %d at %#x with %d bytes payload, want an empty header at %#x", i, b.Offset, b.Header.PayloadSize, want[i].Offset)
+		}
+		if rest := image[b.End():want[i].End()]; !bytes.Equal(rest, bytes.Repeat([]byte{0xff}, len(rest))) {
+			t.Errorf("truncate: blob %d not padded: % x", i, rest)
+		}
+	}
+	var u UswidSoftwareIdentity
+	if _, err := u.FromImage(image); err != nil || len(u.Identities) != 0 {
+		t.Errorf("truncate: FromImage = %v, %v, want no identities", softwareNames(u), err)
+	}
+
+	if regions, err := Strip(bytes.Repeat([]byte{0xff}, 0x100), StripErase, 0xff); err != nil || len(regions) != 0 {
+		t.Errorf("Strip of an image without blobs = %+v, %v", regions, err)
+	}
+}
+
+func TestStripNotStrippable(t *testing.T) {
+	tiano := make([]byte, 20)
+	copy(tiano, uefi.TianoCustomDecompressGUID[:])
+	tiano[16], tiano[18] = 24, 1 // data offset, processing required
+	tiano = uefiSection(uefi.SectionGUIDDefined, append(tiano, "compressed"...))
+
+	for _, test := range []struct {
+		name       string
+		section    []byte
+		strippable bool
+	}{
+		{"raw section", uefiSection(uefi.SectionRaw, testBlob(t, "plain")), true},
+		{"LZMA section", uefiLZMASection(t, uefiSection(uefi.SectionRaw, testBlob(t, "compressed"))), false},
+		{"Tiano section", tiano, false},
+	} {
+		plain := testBlob(t, "outside")
+		image := append(append([]byte{}, plain...), uefiVolume([]uefi.GUID{lzmaFileGUID}, [][]byte{test.section})...)
+		regions, err := Strip(image, StripErase, 0xff)
+		if test.strippable {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrNotStrippable) {
+			t.Errorf("%s: Strip = %v, want %v", test.name, err, ErrNotStrippable)
+			continue
+		}
+		if !strings.Contains(err.Error(), lzmaFileGUID.String()) {
+			t.Errorf("%s: error %q does not name the FFS file", test.name, err)
+		}
+		// the visible blobs are stripped anyway
+		if len(regions) != 1 || regions[0] != (Region{0, len(plain)}) {
+			t.Errorf("%s: regions = %+v", test.name, regions)
+		}
+	}
+}
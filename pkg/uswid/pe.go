@@ -0,0 +1,19 @@
+package uswid
+
+import (
+	"io"
+
+	"github.com/9elements/goswid/pkg/pesection"
+)
+
+// FromPE decodes the uSWID data stored in the section called name (usually
+// pesection.DefaultName) of the PE/COFF image r, e.g. an EFI application or
+// driver. The section holds either uSWID blobs or plain CoSWID tags.
+// pesection.ErrNotFound is returned if there is no such section.
+func (uswid *UswidSoftwareIdentity) FromPE(r io.ReaderAt, name string) error {
+	data, err := pesection.Read(r, name)
+	if err != nil {
+		return err
+	}
+	return uswid.fromSection(data)
+}
@@ -0,0 +1,57 @@
+package uswid
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/CodingVoid/swid"
+)
+
+func recursivePlantUML(stringBuilder *strings.Builder, beenThere []bool, uid *UswidSoftwareIdentity, i int) {
+	beenThere[i] = true
+	currentId := uid.Identities[i]
+	stringBuilder.WriteString(`object "`)
+	stringBuilder.WriteString(currentId.SoftwareName)
+	stringBuilder.WriteString(`" as `)
+	stringBuilder.WriteString(strconv.Itoa(i))
+	stringBuilder.WriteRune('\n')
+	if currentId.Links == nil {
+		return
+	}
+	for _, link := range *currentId.Links {
+		//TODO comparing the string values is quite slow, but the values are probably saved as strings inside link.Rel
+		if link.Rel.String() == swid.NewRel(swid.RelRequires).String() || link.Rel.String() == swid.NewRel(swid.RelCompiler).String() {
+			for index, id := range uid.Identities {
+				// speed it up a bit by ignoring tag's where we have already been
+				if beenThere[index] {
+					continue
+				}
+				if id.TagID.URI() == link.Href {
+					recursivePlantUML(stringBuilder, beenThere[:], uid, index)
+					stringBuilder.WriteByte('"')
+					stringBuilder.WriteString(strconv.Itoa(index))
+					stringBuilder.WriteString(`" --> "`)
+					stringBuilder.WriteString(strconv.Itoa(i))
+					stringBuilder.WriteByte('"')
+					stringBuilder.WriteString("\n")
+				}
+			}
+		}
+	}
+}
+
+// ToPlantUML renders the requires and compiler links between the identities as
+// PlantUML object diagram.
+func (uswid UswidSoftwareIdentity) ToPlantUML() ([]byte, error) {
+	var strbuilder strings.Builder
+	beenThere := make([]bool, len(uswid.Identities))
+	strbuilder.WriteString("@startuml\n")
+	for i, been := range beenThere {
+		if been {
+			continue
+		}
+		recursivePlantUML(&strbuilder, beenThere[:], &uswid, i)
+	}
+	strbuilder.WriteString("@enduml")
+	return []byte(strbuilder.String()), nil
+}
@@ -0,0 +1,334 @@
+package uswid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodingVoid/swid"
+	"github.com/google/uuid"
+)
+
+// Helpers for the SBOM formats which map CoSWID identities to other data
+// models.
+
+// hasRel reports whether l is a link of the relation rel.
+func hasRel(l swid.Link, rel int64) bool {
+	return l.Rel.String() == swid.NewRel(rel).String()
+}
+
+// links returns the links of id, or nil.
+func links(id swid.SoftwareIdentity) []swid.Link {
+	if id.Links == nil {
+		return nil
+	}
+	return *id.Links
+}
+
+// identityByHref returns the index of the identity a link points to, or -1
+// if it is not part of uswid.
+func (uswid UswidSoftwareIdentity) identityByHref(href string) int {
+	for i, id := range uswid.Identities {
+		if id.TagID.URI() == href || id.TagID.String() == href {
+			return i
+		}
+	}
+	return -1
+}
+
+// entityRoles returns the role names of e, e.g. "tagCreator".
+func entityRoles(e swid.Entity) []string {
+	return strings.Fields(e.Roles.String())
+}
+
+// entityWithRole returns the first entity of id having one of roles, the
+// roles are tried in order.
+func entityWithRole(id swid.SoftwareIdentity, roles ...string) *swid.Entity {
+	for _, role := range roles {
+		for i, e := range id.Entities {
+			for _, r := range entityRoles(e) {
+				if r == role {
+					return &id.Entities[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// licenseHrefs returns the targets of the license links of id.
+func licenseHrefs(id swid.SoftwareIdentity) []string {
+	var hrefs []string
+	for _, l := range links(id) {
+		if hasRel(l, swid.RelLicense) && l.Href != "" {
+			hrefs = append(hrefs, l.Href)
+		}
+	}
+	return hrefs
+}
+
+var spdxLicenseURL = regexp.MustCompile(`^https?://spdx\.org/licenses/([A-Za-z0-9.+-]+?)(\.html|\.json)?$`)
+
+// spdxLicense returns the SPDX license identifier a license link points to,
+// e.g. GPL-2.0-only for https://spdx.org/licenses/GPL-2.0-only.html. Hrefs
+// which are identifiers of spdxLicenseIDs, optionally followed by "+", are
+// returned in their canonical spelling. Anything else, like a link to a
+// COPYING file, is no SPDX license.
+func spdxLicense(href string) (string, bool) {
+	if m := spdxLicenseURL.FindStringSubmatch(href); m != nil {
+		return m[1], true
+	}
+	if id, ok := knownSPDXLicenses[strings.ToLower(href)]; ok {
+		return id, true
+	}
+	if base := strings.TrimSuffix(href, "+"); base != href {
+		if id, ok := knownSPDXLicenses[strings.ToLower(base)]; ok {
+			return id + "+", true
+		}
+	}
+	return "", false
+}
+
+// softwareMeta returns the first non-empty value field returns for the
+// software meta entries of id.
+func softwareMeta(id swid.SoftwareIdentity, field func(swid.SoftwareMeta) string) string {
+	if id.SoftwareMetas == nil {
+		return ""
+	}
+	for _, m := range *id.SoftwareMetas {
+		if v := field(m); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// payloadFile is a file of the payload of an identity along with its path.
+type payloadFile struct {
+	Path string
+	swid.File
+}
+
+// payloadFiles returns all files of the payload of id, including the files
+// in directories.
+func payloadFiles(id swid.SoftwareIdentity) []payloadFile {
+	if id.Payload == nil {
+		return nil
+	}
+	var files []payloadFile
+	var walk func(pe swid.PathElements, dir string)
+	walk = func(pe swid.PathElements, dir string) {
+		if pe.Files != nil {
+			for _, f := range *pe.Files {
+				files = append(files, payloadFile{Path: path.Join(dir, f.Location, f.FsName), File: f})
+			}
+		}
+		if pe.Directories != nil {
+			for _, d := range *pe.Directories {
+				if d.PathElements != nil {
+					walk(*d.PathElements, path.Join(dir, d.Location, d.FsName))
+				}
+			}
+		}
+	}
+	walk(id.Payload.PathElements, "")
+	return files
+}
+
+// creationTime returns the time documents are created at. It is taken from
+// SOURCE_DATE_EPOCH if set, so builds can produce reproducible SBOMs.
+func creationTime() time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+	}
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// rootIdentities returns the indices of the identities which are no target
+// of requires or compiler links of other identities, or the first identity
+// if there are none.
+func (uswid UswidSoftwareIdentity) rootIdentities() []int {
+	target := make([]bool, len(uswid.Identities))
+	for i, id := range uswid.Identities {
+		for _, l := range links(id) {
+			if !hasRel(l, swid.RelRequires) && !hasRel(l, swid.RelCompiler) {
+				continue
+			}
+			if j := uswid.identityByHref(l.Href); j != -1 && j != i {
+				target[j] = true
+			}
+		}
+	}
+	var roots []int
+	for i, t := range target {
+		if !t {
+			roots = append(roots, i)
+		}
+	}
+	if len(roots) == 0 && len(uswid.Identities) > 0 {
+		roots = []int{0}
+	}
+	return roots
+}
+
+// sbomComponent is a component or package read from another SBOM format,
+// which is turned into a CoSWID identity.
+type sbomComponent struct {
+	// ref identifies the component within the document, e.g. the bom-ref or
+	// SPDX identifier
+	ref string
+	// tagID is used as tag-id if set, otherwise the tag-id is derived from
+	// key, which should be stable across documents (e.g. a purl)
+	tagID string
+	key   string
+
+	name        string
+	version     string
+	summary     string
+	description string
+	supplier    string
+	supplierURL string
+	author      string
+	// license link targets
+	licenses []string
+	// refs of the components this one requires and is built with
+	requires  []string
+	compilers []string
+}
+
+var licenseExpressionOperators = map[string]bool{"AND": true, "OR": true, "WITH": true, "NONE": true, "NOASSERTION": true}
+
+// licenseExpressionIDs returns the license identifiers of an SPDX license
+// expression. Exceptions following WITH are dropped, a trailing "+" for
+// "or later" is kept as spdxLicense accepts it.
+func licenseExpressionIDs(expression string) []string {
+	var ids []string
+	tokens := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expression))
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] == "WITH" {
+			i++
+			continue
+		}
+		if !licenseExpressionOperators[tokens[i]] {
+			ids = append(ids, tokens[i])
+		}
+	}
+	return ids
+}
+
+// spdxLicenseHref returns the link target of an SPDX license identifier.
+func spdxLicenseHref(id string) string {
+	return "https://spdx.org/licenses/" + id + ".html"
+}
+
+// fromSBOM turns components into identities. Tag-ids are derived from the
+// component keys, so reading the same document twice gives the same tags.
+// The tag creator entity is tagCreator if set, the supplier or author of a
+// component otherwise. CoSWID requires a tag creator, so components of
+// documents naming none of them get an entity called "goswid" without reg-id,
+// which only tells that the tag was generated.
+func fromSBOM(tagCreator string, components []sbomComponent) (UswidSoftwareIdentity, error) {
+	var uswid UswidSoftwareIdentity
+	if len(components) == 0 {
+		return uswid, errors.New("no components found")
+	}
+	tagIDs := map[string]string{}
+	for _, c := range components {
+		tagID := c.tagID
+		if tagID == "" {
+			tagID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(c.key)).String()
+		}
+		if c.ref != "" {
+			tagIDs[c.ref] = tagID
+		}
+		id, err := swid.NewTag(tagID, c.name, c.version)
+		if err != nil {
+			return uswid, fmt.Errorf("component %s: %w", c.name, err)
+		}
+
+		// entities with the same name are merged
+		var names []string
+		roles := map[string][]interface{}{}
+		regIDs := map[string]string{}
+		addEntity := func(name string, role int64) {
+			if name == "" {
+				return
+			}
+			if _, ok := roles[name]; !ok {
+				names = append(names, name)
+			}
+			roles[name] = append(roles[name], role)
+		}
+		creator := tagCreator
+		for _, name := range []string{c.supplier, c.author, "goswid"} {
+			if creator == "" {
+				creator = name
+			}
+		}
+		addEntity(creator, swid.RoleTagCreator)
+		addEntity(c.author, swid.RoleSoftwareCreator)
+		addEntity(c.supplier, swid.RoleDistributor)
+		if c.supplier != "" {
+			regIDs[c.supplier] = c.supplierURL
+		}
+		for _, name := range names {
+			e, err := swid.NewEntity(name, roles[name]...)
+			if err != nil {
+				return uswid, err
+			}
+			e.RegID = regIDs[name]
+			if err := id.AddEntity(*e); err != nil {
+				return uswid, err
+			}
+		}
+
+		if c.summary != "" || c.description != "" {
+			if err := id.AddSoftwareMeta(swid.SoftwareMeta{Summary: c.summary, Description: c.description}); err != nil {
+				return uswid, err
+			}
+		}
+		for _, href := range c.licenses {
+			link, err := swid.NewLink(href, *swid.NewRel(swid.RelLicense))
+			if err != nil {
+				return uswid, err
+			}
+			if err := id.AddLink(*link); err != nil {
+				return uswid, err
+			}
+		}
+		uswid.Identities = append(uswid.Identities, *id)
+	}
+
+	for i, c := range components {
+		for _, dep := range []struct {
+			refs []string
+			rel  int64
+		}{{c.requires, swid.RelRequires}, {c.compilers, swid.RelCompiler}} {
+			for _, ref := range dep.refs {
+				tagID, ok := tagIDs[ref]
+				if !ok {
+					continue
+				}
+				target := swid.NewTagID(tagID)
+				if target == nil {
+					continue
+				}
+				link, err := swid.NewLink(target.URI(), *swid.NewRel(dep.rel))
+				if err != nil {
+					return uswid, err
+				}
+				if err := uswid.Identities[i].AddLink(*link); err != nil {
+					return uswid, err
+				}
+			}
+		}
+	}
+	return uswid, nil
+}
@@ -0,0 +1,27 @@
+package uswid
+
+import "testing"
+
+func TestSPDXLicense(t *testing.T) {
+	tests := []struct {
+		href string
+		id   string
+		ok   bool
+	}{
+		{"https://spdx.org/licenses/GPL-2.0-only.html", "GPL-2.0-only", true},
+		{"http://spdx.org/licenses/MIT", "MIT", true},
+		{"BSD-2-Clause-Patent", "BSD-2-Clause-Patent", true},
+		{"apache-2.0", "Apache-2.0", true},
+		{"GPL-2.0+", "GPL-2.0+", true},
+		{"MPL-2.0+", "MPL-2.0+", true},
+		{"COPYING", "", false},
+		{"LICENSE.txt", "", false},
+		{"https://example.com/eula", "", false},
+	}
+	for _, tt := range tests {
+		id, ok := spdxLicense(tt.href)
+		if id != tt.id || ok != tt.ok {
+			t.Errorf("spdxLicense(%q) = %q, %v, want %q, %v", tt.href, id, ok, tt.id, tt.ok)
+		}
+	}
+}
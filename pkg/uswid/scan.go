@@ -0,0 +1,133 @@
+package uswid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Blob is a single uSWID blob found in a binary image.
+type Blob struct {
+	Offset        int // offset of the magic value in the image
+	PayloadOffset int // offset of the payload in the image
+	Header        Header
+	Payload       []byte // CoSWID payload, still compressed
+}
+
+// End returns the offset of the first byte after the blob.
+func (b Blob) End() int {
+	return b.PayloadOffset + len(b.Payload)
+}
+
+func parseBlob(image []byte, offset int) (Blob, error) {
+	b := Blob{Offset: offset}
+	h, err := ParseHeader(image[offset:])
+	b.Header = h
+	if err != nil {
+		return b, err
+	}
+	b.PayloadOffset = offset + int(h.Size)
+	if uint64(len(image)-b.PayloadOffset) < uint64(h.PayloadSize) {
+		return b, fmt.Errorf("%w: %d bytes payload, %d bytes left", ErrPayloadExceedsBlob, h.PayloadSize, len(image)-b.PayloadOffset)
+	}
+	b.Payload = image[b.PayloadOffset : b.PayloadOffset+int(h.PayloadSize)]
+	return b, nil
+}
+
+// errStopScan is returned by the callback of scan to accept a blob and stop
+// scanning.
+var errStopScan = errors.New("stop scanning")
+
+// scan calls fn for every uSWID blob in image. Firmware code and data can
+// contain the magic value by chance, so matches whose header can't be parsed
+// or which fn rejects are skipped, and scanning continues right after their
+// magic value. The error of the first skipped match is only returned if no
+// blob was accepted at all.
+func scan(image []byte, fn func(Blob) error) error {
+	var firstErr error
+	found := false
+	pos := 0
+	for {
+		i := bytes.Index(image[pos:], magic)
+		if i == -1 {
+			break
+		}
+		b, err := parseBlob(image, pos+i)
+		if err == nil {
+			err = fn(b)
+		}
+		if err == errStopScan {
+			return nil
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("uSWID data at offset %#x: %w", pos+i, err)
+			}
+			pos += i + 1
+			continue
+		}
+		found = true
+		pos = b.End()
+	}
+	if !found {
+		return firstErr
+	}
+	return nil
+}
+
+// Scan returns all uSWID blobs found in image in the order they appear. Only
+// blobs whose payload decodes are returned, see scan.
+func Scan(image []byte) ([]Blob, error) {
+	var blobs []Blob
+	err := scan(image, func(b Blob) error {
+		var ids UswidSoftwareIdentity
+		if err := ids.FromBlob(b); err != nil {
+			return err
+		}
+		blobs = append(blobs, b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// FromBlob decodes the CoSWID tags of a single uSWID blob.
+func (uswid *UswidSoftwareIdentity) FromBlob(b Blob) error {
+	if err := uswid.FromCBORCompression(b.Payload, b.Header.Compression); err != nil {
+		return fmt.Errorf("extract CBOR: %w", err)
+	}
+	return nil
+}
+
+// fromBlob decodes b like FromBlob, but leaves uswid untouched if the
+// payload can't be decoded completely.
+func (uswid *UswidSoftwareIdentity) fromBlob(b Blob) error {
+	var ids UswidSoftwareIdentity
+	if err := ids.FromBlob(b); err != nil {
+		return err
+	}
+	uswid.Identities = append(uswid.Identities, ids.Identities...)
+	return nil
+}
+
+// FromImage decodes all uSWID blobs found in image and returns them. Magic
+// values which turn out not to start a valid blob are skipped.
+func (uswid *UswidSoftwareIdentity) FromImage(image []byte) ([]Blob, error) {
+	var blobs []Blob
+	err := scan(image, func(b Blob) error {
+		if err := uswid.fromBlob(b); err != nil {
+			return err
+		}
+		blobs = append(blobs, b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(blobs) == 0 {
+		return nil, ErrNotFound
+	}
+	return blobs, nil
+}
@@ -0,0 +1,216 @@
+package uswid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readFixture(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func softwareNames(u UswidSoftwareIdentity) []string {
+	var names []string
+	for _, id := range u.Identities {
+		names = append(names, id.SoftwareName)
+	}
+	return names
+}
+
+func TestFromImage(t *testing.T) {
+	tests := []struct {
+		file         string
+		versions     []uint8
+		compressions []Compression
+		offsets      []int
+		names        []string
+	}{
+		{
+			file:         "v1.uswid",
+			versions:     []uint8{1},
+			compressions: []Compression{CompressionNone},
+			offsets:      []int{0},
+			names:        []string{"foo"},
+		},
+		{
+			file:         "v2-zlib.uswid",
+			versions:     []uint8{2},
+			compressions: []Compression{CompressionZlib},
+			offsets:      []int{0},
+			names:        []string{"bar"},
+		},
+		{
+			file:         "v3-lzma.uswid",
+			versions:     []uint8{3},
+			compressions: []Compression{CompressionLZMA},
+			offsets:      []int{0},
+			names:        []string{"baz"},
+		},
+		{
+			file:         "mixed.rom",
+			versions:     []uint8{1, 2, 3},
+			compressions: []Compression{CompressionNone, CompressionZlib, CompressionLZMA},
+			offsets:      []int{0x100, 0x400, 0x800},
+			names:        []string{"foo", "bar", "baz"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			image := readFixture(t, tt.file)
+			var u UswidSoftwareIdentity
+			blobs, err := u.FromImage(image)
+			if err != nil {
+				t.Fatalf("FromImage: %v", err)
+			}
+			var versions []uint8
+			var compressions []Compression
+			var offsets []int
+			for _, b := range blobs {
+				versions = append(versions, b.Header.Version)
+				compressions = append(compressions, b.Header.Compression)
+				offsets = append(offsets, b.Offset)
+			}
+			if !reflect.DeepEqual(versions, tt.versions) {
+				t.Errorf("header versions = %v, want %v", versions, tt.versions)
+			}
+			if !reflect.DeepEqual(compressions, tt.compressions) {
+				t.Errorf("compressions = %v, want %v", compressions, tt.compressions)
+			}
+			if !reflect.DeepEqual(offsets, tt.offsets) {
+				t.Errorf("offsets = %#x, want %#x", offsets, tt.offsets)
+			}
+			if names := softwareNames(u); !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("software names = %v, want %v", names, tt.names)
+			}
+
+			// the streaming scanner has to find the same blobs
+			var streamed UswidSoftwareIdentity
+			streamedBlobs, err := streamed.FromReader(bytes.NewReader(image))
+			if err != nil {
+				t.Fatalf("FromReader: %v", err)
+			}
+			if len(streamedBlobs) != len(blobs) {
+				t.Fatalf("FromReader found %d blobs, want %d", len(streamedBlobs), len(blobs))
+			}
+			for i := range blobs {
+				if streamedBlobs[i].Offset != blobs[i].Offset || !bytes.Equal(streamedBlobs[i].Payload, blobs[i].Payload) {
+					t.Errorf("FromReader blob %d differs from FromImage", i)
+				}
+			}
+		})
+	}
+}
+
+func TestFromUSWIDVersion1HeaderSize(t *testing.T) {
+	// a version 1 header extended by 4 bytes, the payload starts after the
+	// header size stored in the header
+	v1 := readFixture(t, "v1.uswid")
+	payload := v1[headerSizeV1:]
+	blob := append([]byte{}, v1[:headerSizeV1]...)
+	binary.LittleEndian.PutUint16(blob[17:19], headerSizeV1+4)
+	blob = append(blob, 0xde, 0xad, 0xbe, 0xef)
+	blob = append(blob, payload...)
+
+	var u UswidSoftwareIdentity
+	offset, err := u.FromUSWID(append([]byte{0, 0, 0}, blob...))
+	if err != nil {
+		t.Fatalf("FromUSWID: %v", err)
+	}
+	if offset != 3 {
+		t.Errorf("offset = %d, want 3", offset)
+	}
+	if names := softwareNames(u); !reflect.DeepEqual(names, []string{"foo"}) {
+		t.Errorf("software names = %v, want [foo]", names)
+	}
+}
+
+func TestFromImageNotFound(t *testing.T) {
+	var u UswidSoftwareIdentity
+	if _, err := u.FromImage(bytes.Repeat([]byte{0xff}, 0x1000)); err != ErrNotFound {
+		t.Errorf("FromImage error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestScanSkipsFalseMagic(t *testing.T) {
+	v2 := readFixture(t, "v2-zlib.uswid")
+	badVersion := append(append([]byte{}, magic...), 9, 0, 0, 0, 0, 0, 0)
+	// a valid header whose payload is no CBOR and covers the real blob
+	badPayload := append([]byte{}, v2[:headerSizeV2]...)
+	binary.LittleEndian.PutUint32(badPayload[19:23], 0x200)
+	badPayload[23] = 0
+	// a payload larger than the scanner window, which has to be searched
+	// again after it turned out not to be CBOR
+	hugePayload := append([]byte{}, badPayload...)
+	binary.LittleEndian.PutUint32(hugePayload[19:23], 2*DefaultWindowSize)
+
+	tests := []struct {
+		name   string
+		prefix []byte
+		size   int
+	}{
+		{name: "unknown version", prefix: badVersion, size: 0x1000},
+		{name: "truncated header", prefix: magic, size: 0x1000},
+		{name: "bogus payload", prefix: badPayload, size: 0x1000},
+		{name: "bogus payload beyond window", prefix: hugePayload, size: 3 * DefaultWindowSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image := bytes.Repeat([]byte{0xff}, tt.size)
+			copy(image[0x40:], tt.prefix)
+			offset := 0x100
+			copy(image[offset:], v2)
+
+			var u UswidSoftwareIdentity
+			blobs, err := u.FromImage(image)
+			if err != nil {
+				t.Fatalf("FromImage: %v", err)
+			}
+			if len(blobs) != 1 || blobs[0].Offset != offset {
+				t.Errorf("FromImage found %d blobs, want one at %#x", len(blobs), offset)
+			}
+			if names := softwareNames(u); !reflect.DeepEqual(names, []string{"bar"}) {
+				t.Errorf("software names = %v, want [bar]", names)
+			}
+
+			var streamed UswidSoftwareIdentity
+			blobs, err = streamed.FromReader(bytes.NewReader(image))
+			if err != nil {
+				t.Fatalf("FromReader: %v", err)
+			}
+			if len(blobs) != 1 || blobs[0].Offset != offset {
+				t.Errorf("FromReader found %d blobs, want one at %#x", len(blobs), offset)
+			}
+			if names := softwareNames(streamed); !reflect.DeepEqual(names, []string{"bar"}) {
+				t.Errorf("software names = %v, want [bar]", names)
+			}
+
+			var single UswidSoftwareIdentity
+			if got, err := single.FromUSWID(image); err != nil || got != offset {
+				t.Errorf("FromUSWID = %#x, %v, want %#x", got, err, offset)
+			}
+		})
+	}
+}
+
+func TestScanOnlyFalseMagic(t *testing.T) {
+	image := bytes.Repeat([]byte{0xff}, 0x1000)
+	copy(image[0x40:], append(append([]byte{}, magic...), 9, 0, 0, 0, 0, 0, 0))
+
+	var u UswidSoftwareIdentity
+	if _, err := u.FromImage(image); !errors.Is(err, ErrUnknownHeaderVersion) {
+		t.Errorf("FromImage error = %v, want %v", err, ErrUnknownHeaderVersion)
+	}
+	if _, err := u.FromReader(bytes.NewReader(image)); !errors.Is(err, ErrUnknownHeaderVersion) {
+		t.Errorf("FromReader error = %v, want %v", err, ErrUnknownHeaderVersion)
+	}
+}
@@ -0,0 +1,637 @@
+package uswid
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodingVoid/swid"
+	"github.com/google/uuid"
+)
+
+// SPDX 2.3 document, only the fields goswid fills in or reads
+type spdxDocument struct {
+	SPDXVersion                string                 `json:"spdxVersion"`
+	DataLicense                string                 `json:"dataLicense"`
+	SPDXID                     string                 `json:"SPDXID"`
+	Name                       string                 `json:"name"`
+	DocumentNamespace          string                 `json:"documentNamespace"`
+	CreationInfo               spdxCreationInfo       `json:"creationInfo"`
+	Packages                   []spdxPackage          `json:"packages,omitempty"`
+	HasExtractedLicensingInfos []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+	Relationships              []spdxRelationship     `json:"relationships,omitempty"`
+	// only read, goswid uses DESCRIBES relationships
+	DocumentDescribes []string `json:"documentDescribes,omitempty"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	Supplier         string            `json:"supplier,omitempty"`
+	Originator       string            `json:"originator,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	Summary          string            `json:"summary,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+
+	// the payload files, only tag-value documents list them
+	files []spdxFile
+}
+
+type spdxFile struct {
+	SPDXID           string
+	FileName         string
+	Checksums        []spdxChecksum
+	LicenseConcluded string
+	CopyrightText    string
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxExtractedLicense struct {
+	LicenseID     string   `json:"licenseId"`
+	ExtractedText string   `json:"extractedText"`
+	Name          string   `json:"name,omitempty"`
+	SeeAlsos      []string `json:"seeAlsos,omitempty"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const (
+	spdxNoAssertion = "NOASSERTION"
+	spdxDocumentID  = "SPDXRef-DOCUMENT"
+)
+
+// SPDX names of the CoSWID hash algorithms, truncated SHA-256 hashes have
+// none
+var spdxHashAlgorithms = map[uint64]string{
+	swid.Sha256:   "SHA256",
+	swid.Sha384:   "SHA384",
+	swid.Sha512:   "SHA512",
+	swid.Sha3_256: "SHA3-256",
+	swid.Sha3_384: "SHA3-384",
+	swid.Sha3_512: "SHA3-512",
+}
+
+var spdxIDInvalid = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// spdxIDs hands out unique SPDX identifiers.
+type spdxIDs map[string]bool
+
+func (ids spdxIDs) next(prefix string, name string) string {
+	base := prefix + strings.Trim(spdxIDInvalid.ReplaceAllString(name, "-"), "-")
+	id := base
+	for n := 2; ids[id]; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	ids[id] = true
+	return id
+}
+
+// toSPDXChecksum returns the SPDX checksum of a CoSWID hash entry.
+func toSPDXChecksum(h *swid.HashEntry) (spdxChecksum, bool) {
+	if h == nil {
+		return spdxChecksum{}, false
+	}
+	alg, ok := spdxHashAlgorithms[h.HashAlgID]
+	if !ok {
+		return spdxChecksum{}, false
+	}
+	return spdxChecksum{Algorithm: alg, ChecksumValue: hex.EncodeToString(h.HashValue)}, true
+}
+
+// toSPDX maps the identities to an SPDX document. Every identity becomes a
+// package, requires links become DEPENDS_ON and compiler links BUILD_TOOL_OF
+// relationships. Links to identities which are not part of uswid are
+// dropped. If files is set, the payload files are added to the packages as
+// well and the packages are marked as analyzed.
+//
+// SPDX requires a SHA1 checksum for every file, which CoSWID tags don't have,
+// so the files only carry the CoSWID hash and such documents are not
+// conformant. The JSON export therefore only uses the hashes as package
+// checksums.
+func (uswid UswidSoftwareIdentity) toSPDX(created time.Time, files bool) spdxDocument {
+	doc := spdxDocument{
+		SPDXVersion:  "SPDX-2.3",
+		DataLicense:  "CC0-1.0",
+		SPDXID:       spdxDocumentID,
+		CreationInfo: spdxCreationInfo{Created: created.Format(time.RFC3339)},
+	}
+	ids := spdxIDs{spdxDocumentID: true}
+	creators := map[string]bool{}
+	licenseRefs := map[string]string{}
+	packageIDs := make([]string, len(uswid.Identities))
+	// the namespace has to be unique per document, derive it from the
+	// content so converting the same tags gives the same document
+	var fingerprint strings.Builder
+
+	for i, id := range uswid.Identities {
+		fingerprint.WriteString(id.TagID.String() + "\x00" + id.SoftwareVersion + "\x00")
+		p := spdxPackage{
+			SPDXID:           ids.next("SPDXRef-Package-", id.TagID.String()),
+			Name:             id.SoftwareName,
+			VersionInfo:      id.SoftwareVersion,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+			Summary:          softwareMeta(id, func(m swid.SoftwareMeta) string { return m.Summary }),
+			Description:      softwareMeta(id, func(m swid.SoftwareMeta) string { return m.Description }),
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "SECURITY",
+				ReferenceType:     "swid",
+				ReferenceLocator:  id.TagID.URI(),
+			}},
+		}
+		packageIDs[i] = p.SPDXID
+
+		supplier := entityWithRole(id, "distributor", "softwareCreator", "maintainer")
+		if supplier != nil {
+			p.Supplier = "Organization: " + supplier.EntityName
+		}
+		if creator := entityWithRole(id, "softwareCreator"); creator != nil && creator != supplier {
+			p.Originator = "Organization: " + creator.EntityName
+		}
+		if tagCreator := entityWithRole(id, "tagCreator"); tagCreator != nil && !creators[tagCreator.EntityName] {
+			creators[tagCreator.EntityName] = true
+			doc.CreationInfo.Creators = append(doc.CreationInfo.Creators, "Organization: "+tagCreator.EntityName)
+		}
+
+		var licenses []string
+		for _, href := range licenseHrefs(id) {
+			license, ok := spdxLicense(href)
+			if !ok {
+				if license, ok = licenseRefs[href]; !ok {
+					license = ids.next("LicenseRef-", href)
+					licenseRefs[href] = license
+					extracted := spdxExtractedLicense{
+						LicenseID:     license,
+						ExtractedText: "See " + href,
+						Name:          href,
+					}
+					if u, err := url.Parse(href); err == nil && u.Scheme != "" {
+						// seeAlsos are URLs, not file names
+						extracted.SeeAlsos = []string{href}
+					}
+					doc.HasExtractedLicensingInfos = append(doc.HasExtractedLicensingInfos, extracted)
+				}
+			}
+			licenses = append(licenses, license)
+		}
+		p.LicenseDeclared = spdxNoAssertion
+		if len(licenses) > 0 {
+			p.LicenseDeclared = strings.Join(licenses, " AND ")
+		}
+
+		seen := map[spdxChecksum]bool{}
+		for _, f := range payloadFiles(id) {
+			sum, ok := toSPDXChecksum(f.Hash)
+			if ok && !seen[sum] {
+				seen[sum] = true
+				p.Checksums = append(p.Checksums, sum)
+			}
+			if files {
+				file := spdxFile{
+					SPDXID:           ids.next("SPDXRef-File-", f.Path),
+					FileName:         "./" + strings.TrimPrefix(f.Path, "/"),
+					LicenseConcluded: spdxNoAssertion,
+					CopyrightText:    spdxNoAssertion,
+				}
+				if ok {
+					file.Checksums = []spdxChecksum{sum}
+				}
+				p.files = append(p.files, file)
+			}
+		}
+		// SPDX 2.3 no longer requires a verification code for analyzed packages
+		p.FilesAnalyzed = len(p.files) > 0
+		doc.Packages = append(doc.Packages, p)
+	}
+	doc.CreationInfo.Creators = append(doc.CreationInfo.Creators, "Tool: goswid")
+
+	roots := uswid.rootIdentities()
+	doc.Name = "goswid SBOM"
+	if len(roots) > 0 {
+		root := uswid.Identities[roots[0]]
+		doc.Name = strings.TrimSpace(root.SoftwareName + " " + root.SoftwareVersion)
+	}
+	doc.DocumentNamespace = "https://spdx.org/spdxdocs/" + spdxIDInvalid.ReplaceAllString(doc.Name, "-") + "-" +
+		uuid.NewSHA1(uuid.NameSpaceURL, []byte(fingerprint.String())).String()
+
+	for _, i := range roots {
+		doc.Relationships = append(doc.Relationships, spdxRelationship{spdxDocumentID, "DESCRIBES", packageIDs[i]})
+	}
+	for _, p := range doc.Packages {
+		for _, f := range p.files {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{p.SPDXID, "CONTAINS", f.SPDXID})
+		}
+	}
+	for i, id := range uswid.Identities {
+		for _, l := range links(id) {
+			j := uswid.identityByHref(l.Href)
+			if j == -1 || j == i {
+				continue
+			}
+			switch {
+			case hasRel(l, swid.RelRequires):
+				doc.Relationships = append(doc.Relationships, spdxRelationship{packageIDs[i], "DEPENDS_ON", packageIDs[j]})
+			case hasRel(l, swid.RelCompiler):
+				doc.Relationships = append(doc.Relationships, spdxRelationship{packageIDs[j], "BUILD_TOOL_OF", packageIDs[i]})
+			}
+		}
+	}
+	return doc
+}
+
+// ToSPDXJSON exports the identities as SPDX 2.3 JSON document.
+func (uswid UswidSoftwareIdentity) ToSPDXJSON() ([]byte, error) {
+	buf, err := json.MarshalIndent(uswid.toSPDX(creationTime(), false), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("convert to SPDX: %w", err)
+	}
+	return append(buf, '\n'), nil
+}
+
+type spdxJSONCodec struct{}
+
+func (spdxJSONCodec) Name() string         { return "spdx-json" }
+func (spdxJSONCodec) Extensions() []string { return []string{"spdx.json"} }
+func (spdxJSONCodec) Sniff(head []byte) bool {
+	text, ok := textHead(head)
+	return ok && text[0] == '{' && bytes.Contains(text, []byte(`"spdxVersion"`))
+}
+
+func (spdxJSONCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	var doc spdxDocument
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return UswidSoftwareIdentity{}, err
+	}
+	if err := json.Unmarshal(bytes.TrimPrefix(data, utf8BOM), &doc); err != nil {
+		return UswidSoftwareIdentity{}, fmt.Errorf("parse SPDX: %w", err)
+	}
+	return fromSPDX(doc)
+}
+
+func (spdxJSONCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	buf, err := uswid.ToSPDXJSON()
+	return writeAll(w, buf, err)
+}
+
+// spdxEntityName returns the name of an SPDX supplier, originator or
+// creator such as "Organization: ACME (contact@acme.example)", or an empty
+// string for tools and NOASSERTION.
+func spdxEntityName(value string) string {
+	for _, prefix := range []string{"Organization:", "Person:"} {
+		if strings.HasPrefix(value, prefix) {
+			name := strings.TrimSpace(strings.TrimPrefix(value, prefix))
+			if i := strings.LastIndex(name, " ("); i != -1 && strings.HasSuffix(name, ")") {
+				name = name[:i]
+			}
+			return name
+		}
+	}
+	return ""
+}
+
+// fromSPDX maps the packages of an SPDX document to identities. The packages
+// the document describes come first. DEPENDS_ON and DEPENDENCY_OF
+// relationships become requires links, BUILD_TOOL_OF relationships compiler
+// links. Tag-ids are taken from swid external references or derived from the
+// purl or the document namespace and SPDX identifier.
+func fromSPDX(doc spdxDocument) (UswidSoftwareIdentity, error) {
+	var tagCreator string
+	for _, creator := range doc.CreationInfo.Creators {
+		if tagCreator = spdxEntityName(creator); tagCreator != "" {
+			break
+		}
+	}
+
+	extracted := map[string]spdxExtractedLicense{}
+	for _, l := range doc.HasExtractedLicensingInfos {
+		extracted[l.LicenseID] = l
+	}
+	requires := map[string][]string{}
+	compilers := map[string][]string{}
+	described := map[string]bool{}
+	for _, id := range doc.DocumentDescribes {
+		described[id] = true
+	}
+	for _, r := range doc.Relationships {
+		switch r.RelationshipType {
+		case "DESCRIBES":
+			if r.SPDXElementID == spdxDocumentID {
+				described[r.RelatedSPDXElement] = true
+			}
+		case "DESCRIBED_BY":
+			if r.RelatedSPDXElement == spdxDocumentID {
+				described[r.SPDXElementID] = true
+			}
+		case "DEPENDS_ON":
+			requires[r.SPDXElementID] = append(requires[r.SPDXElementID], r.RelatedSPDXElement)
+		case "DEPENDENCY_OF":
+			requires[r.RelatedSPDXElement] = append(requires[r.RelatedSPDXElement], r.SPDXElementID)
+		case "BUILD_TOOL_OF":
+			compilers[r.RelatedSPDXElement] = append(compilers[r.RelatedSPDXElement], r.SPDXElementID)
+		}
+	}
+
+	var first, rest []sbomComponent
+	for _, p := range doc.Packages {
+		c := sbomComponent{
+			ref:         p.SPDXID,
+			key:         doc.DocumentNamespace + "#" + p.SPDXID,
+			name:        p.Name,
+			version:     p.VersionInfo,
+			summary:     p.Summary,
+			description: p.Description,
+			supplier:    spdxEntityName(p.Supplier),
+			author:      spdxEntityName(p.Originator),
+			requires:    requires[p.SPDXID],
+			compilers:   compilers[p.SPDXID],
+		}
+		for _, ref := range p.ExternalRefs {
+			switch ref.ReferenceType {
+			case "swid":
+				c.tagID = strings.TrimPrefix(ref.ReferenceLocator, "swid:")
+			case "purl":
+				c.key = ref.ReferenceLocator
+			}
+		}
+
+		license := p.LicenseDeclared
+		if license == "" || license == spdxNoAssertion || license == "NONE" {
+			license = p.LicenseConcluded
+		}
+		for _, id := range licenseExpressionIDs(license) {
+			if !strings.HasPrefix(id, "LicenseRef-") && !strings.HasPrefix(id, "DocumentRef-") {
+				c.licenses = append(c.licenses, spdxLicenseHref(id))
+				continue
+			}
+			if l, ok := extracted[id]; ok {
+				if len(l.SeeAlsos) > 0 {
+					c.licenses = append(c.licenses, l.SeeAlsos...)
+				} else if l.Name != "" {
+					c.licenses = append(c.licenses, l.Name)
+				}
+			}
+		}
+
+		if described[p.SPDXID] {
+			first = append(first, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	return fromSBOM(tagCreator, append(first, rest...))
+}
+
+// parseSPDXTagValue reads the parts of an SPDX tag-value document fromSPDX
+// needs, files and snippets are skipped.
+func parseSPDXTagValue(r io.Reader) (spdxDocument, error) {
+	var doc spdxDocument
+	var pkg *spdxPackage
+	var license *spdxExtractedLicense
+	// tags following a FileName or Snippet tag describe the file until the
+	// next package
+	inFile := false
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		colon := strings.Index(text, ":")
+		if colon == -1 {
+			return doc, fmt.Errorf("line %d: expected tag: value", line)
+		}
+		tag, value := text[:colon], strings.TrimSpace(text[colon+1:])
+		if strings.HasPrefix(value, "<text>") {
+			value = strings.TrimPrefix(value, "<text>")
+			for !strings.Contains(value, "</text>") {
+				if !s.Scan() {
+					return doc, fmt.Errorf("line %d: unterminated <text>", line)
+				}
+				line++
+				value += "\n" + s.Text()
+			}
+			value = value[:strings.Index(value, "</text>")]
+		}
+
+		switch tag {
+		case "SPDXVersion":
+			doc.SPDXVersion = value
+		case "DocumentNamespace":
+			doc.DocumentNamespace = value
+		case "Creator":
+			doc.CreationInfo.Creators = append(doc.CreationInfo.Creators, value)
+		case "PackageName":
+			doc.Packages = append(doc.Packages, spdxPackage{Name: value})
+			pkg = &doc.Packages[len(doc.Packages)-1]
+			license, inFile = nil, false
+		case "FileName", "SnippetSPDXID":
+			inFile = true
+		case "LicenseID":
+			doc.HasExtractedLicensingInfos = append(doc.HasExtractedLicensingInfos, spdxExtractedLicense{LicenseID: value})
+			license, pkg = &doc.HasExtractedLicensingInfos[len(doc.HasExtractedLicensingInfos)-1], nil
+		case "LicenseName":
+			if license != nil {
+				license.Name = value
+			}
+		case "LicenseCrossReference":
+			if license != nil {
+				license.SeeAlsos = append(license.SeeAlsos, value)
+			}
+		case "Relationship":
+			fields := strings.Fields(value)
+			if len(fields) != 3 {
+				return doc, fmt.Errorf("line %d: invalid relationship %q", line, value)
+			}
+			doc.Relationships = append(doc.Relationships, spdxRelationship{fields[0], fields[1], fields[2]})
+		}
+		if pkg == nil || inFile {
+			continue
+		}
+		switch tag {
+		case "SPDXID":
+			pkg.SPDXID = value
+		case "PackageVersion":
+			pkg.VersionInfo = value
+		case "PackageSupplier":
+			pkg.Supplier = value
+		case "PackageOriginator":
+			pkg.Originator = value
+		case "PackageLicenseConcluded":
+			pkg.LicenseConcluded = value
+		case "PackageLicenseDeclared":
+			pkg.LicenseDeclared = value
+		case "PackageSummary":
+			pkg.Summary = value
+		case "PackageDescription":
+			pkg.Description = value
+		case "ExternalRef":
+			fields := strings.Fields(value)
+			if len(fields) != 3 {
+				return doc, fmt.Errorf("line %d: invalid external reference %q", line, value)
+			}
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{fields[0], fields[1], fields[2]})
+		}
+	}
+	if err := s.Err(); err != nil {
+		return doc, err
+	}
+	if doc.SPDXVersion == "" {
+		return doc, errors.New("missing SPDXVersion")
+	}
+	return doc, nil
+}
+
+// ToSPDXTagValue exports the identities as SPDX 2.3 tag-value document,
+// including the payload files of the packages. The files are checksummed
+// with the CoSWID hashes, they lack the SHA1 checksum SPDX requires. The
+// output only depends on the identities and the creation time, so it can be
+// diffed.
+func (uswid UswidSoftwareIdentity) ToSPDXTagValue() ([]byte, error) {
+	doc := uswid.toSPDX(creationTime(), true)
+	var b strings.Builder
+	tag := func(name string, value string) {
+		if value == "" {
+			return
+		}
+		if strings.ContainsAny(value, "\n\r") {
+			value = "<text>" + value + "</text>"
+		}
+		b.WriteString(name + ": " + value + "\n")
+	}
+
+	tag("SPDXVersion", doc.SPDXVersion)
+	tag("DataLicense", doc.DataLicense)
+	tag("SPDXID", doc.SPDXID)
+	tag("DocumentName", doc.Name)
+	tag("DocumentNamespace", doc.DocumentNamespace)
+	for _, creator := range doc.CreationInfo.Creators {
+		tag("Creator", creator)
+	}
+	tag("Created", doc.CreationInfo.Created)
+
+	for _, p := range doc.Packages {
+		b.WriteString("\n##### Package: " + p.Name + "\n\n")
+		tag("PackageName", p.Name)
+		tag("SPDXID", p.SPDXID)
+		tag("PackageVersion", p.VersionInfo)
+		tag("PackageSupplier", p.Supplier)
+		tag("PackageOriginator", p.Originator)
+		tag("PackageDownloadLocation", p.DownloadLocation)
+		tag("FilesAnalyzed", strconv.FormatBool(p.FilesAnalyzed))
+		for _, sum := range p.Checksums {
+			tag("PackageChecksum", sum.Algorithm+": "+sum.ChecksumValue)
+		}
+		tag("PackageLicenseConcluded", p.LicenseConcluded)
+		tag("PackageLicenseDeclared", p.LicenseDeclared)
+		tag("PackageCopyrightText", p.CopyrightText)
+		tag("PackageSummary", p.Summary)
+		tag("PackageDescription", p.Description)
+		for _, ref := range p.ExternalRefs {
+			tag("ExternalRef", ref.ReferenceCategory+" "+ref.ReferenceType+" "+ref.ReferenceLocator)
+		}
+		for _, f := range p.files {
+			b.WriteString("\n")
+			tag("FileName", f.FileName)
+			tag("SPDXID", f.SPDXID)
+			for _, sum := range f.Checksums {
+				tag("FileChecksum", sum.Algorithm+": "+sum.ChecksumValue)
+			}
+			tag("LicenseConcluded", f.LicenseConcluded)
+			tag("FileCopyrightText", f.CopyrightText)
+		}
+	}
+
+	if len(doc.HasExtractedLicensingInfos) > 0 {
+		b.WriteString("\n##### Other Licenses\n")
+	}
+	for _, l := range doc.HasExtractedLicensingInfos {
+		b.WriteString("\n")
+		tag("LicenseID", l.LicenseID)
+		b.WriteString("ExtractedText: <text>" + l.ExtractedText + "</text>\n")
+		tag("LicenseName", l.Name)
+		for _, ref := range l.SeeAlsos {
+			tag("LicenseCrossReference", ref)
+		}
+	}
+
+	if len(doc.Relationships) > 0 {
+		b.WriteString("\n##### Relationships\n\n")
+	}
+	for _, r := range doc.Relationships {
+		tag("Relationship", r.SPDXElementID+" "+r.RelationshipType+" "+r.RelatedSPDXElement)
+	}
+	return []byte(b.String()), nil
+}
+
+type spdxTagValueCodec struct{}
+
+func (spdxTagValueCodec) Name() string         { return "spdx" }
+func (spdxTagValueCodec) Extensions() []string { return []string{"spdx"} }
+func (spdxTagValueCodec) Sniff(head []byte) bool {
+	text, ok := textHead(head)
+	if !ok {
+		return false
+	}
+	for _, line := range strings.Split(string(text), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return strings.HasPrefix(line, "SPDXVersion:")
+		}
+	}
+	return false
+}
+
+func (spdxTagValueCodec) Decode(r io.Reader, opts CodecOptions) (UswidSoftwareIdentity, error) {
+	doc, err := parseSPDXTagValue(r)
+	if err != nil {
+		return UswidSoftwareIdentity{}, fmt.Errorf("parse SPDX: %w", err)
+	}
+	return fromSPDX(doc)
+}
+
+func (spdxTagValueCodec) Encode(w io.Writer, uswid UswidSoftwareIdentity, opts CodecOptions) error {
+	buf, err := uswid.ToSPDXTagValue()
+	return writeAll(w, buf, err)
+}
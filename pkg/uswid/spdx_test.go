@@ -0,0 +1,65 @@
+package uswid
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToSPDXTagValueFiles(t *testing.T) {
+	var u UswidSoftwareIdentity
+	err := u.FromJSON(`{"tag-id":"foo","software-name":"foo","software-version":"1.0","entity":[{"entity-name":"ACME","role":"tagCreator"}],` +
+		`"payload":{"file":[{"fs-name":"foo.bin","hash":"sha-256:uGhDkVxzT6BoY+WT3Ku7l+Z27h0iXD8xKBQIGAHsPIw="}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := u.ToSPDXTagValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(out)
+	for _, line := range []string{
+		"FilesAnalyzed: true\n",
+		"PackageChecksum: SHA256: b86843915c734fa06863e593dcabbb97e676ee1d225c3f312814081801ec3c8c\n",
+		"FileName: ./foo.bin\n",
+		"SPDXID: SPDXRef-File-foo.bin\n",
+		"FileChecksum: SHA256: b86843915c734fa06863e593dcabbb97e676ee1d225c3f312814081801ec3c8c\n",
+		"Relationship: SPDXRef-Package-foo CONTAINS SPDXRef-File-foo.bin\n",
+	} {
+		if !strings.Contains(doc, line) {
+			t.Errorf("document lacks %q:\n%s", line, doc)
+		}
+	}
+
+	// the files must not end up in the package when reading it back
+	parsed, err := parseSPDXTagValue(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := fromSPDX(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := softwareNames(back); len(got) != 1 || got[0] != "foo" {
+		t.Errorf("got identities %v, want [foo]", got)
+	}
+}
+
+func TestToSPDXJSONWithoutFiles(t *testing.T) {
+	var u UswidSoftwareIdentity
+	err := u.FromJSON(`{"tag-id":"foo","software-name":"foo","software-version":"1.0",` +
+		`"payload":{"file":[{"fs-name":"foo.bin","hash":"sha-256:uGhDkVxzT6BoY+WT3Ku7l+Z27h0iXD8xKBQIGAHsPIw="}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := u.ToSPDXJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(out)
+	if strings.Contains(doc, `"files"`) || strings.Contains(doc, "CONTAINS") {
+		t.Errorf("JSON document lists files:\n%s", doc)
+	}
+	if !strings.Contains(doc, `"filesAnalyzed": false`) {
+		t.Errorf("JSON document lacks filesAnalyzed false:\n%s", doc)
+	}
+}
@@ -0,0 +1,46 @@
+package uswid
+
+import "strings"
+
+// spdxLicenseIDs are the identifiers of the SPDX license list
+// (https://spdx.org/licenses/) which license links may be given as without
+// the URL. It holds the licenses common in firmware and the software it is
+// built from, including the deprecated identifiers still seen in the wild.
+// Links to any license of the list can be given as spdx.org URL.
+var spdxLicenseIDs = []string{
+	"0BSD", "AFL-3.0", "AGPL-3.0", "AGPL-3.0-only", "AGPL-3.0-or-later",
+	"Apache-1.1", "Apache-2.0", "APSL-2.0", "Artistic-1.0", "Artistic-2.0",
+	"Beerware", "blessing", "BlueOak-1.0.0", "BSD-1-Clause", "BSD-2-Clause",
+	"BSD-2-Clause-FreeBSD", "BSD-2-Clause-NetBSD", "BSD-2-Clause-Patent",
+	"BSD-3-Clause", "BSD-3-Clause-Clear", "BSD-3-Clause-LBNL", "BSD-4-Clause",
+	"BSD-Source-Code", "BSL-1.0", "BUSL-1.1", "bzip2-1.0.6", "CC-BY-3.0",
+	"CC-BY-4.0", "CC-BY-NC-4.0", "CC-BY-ND-4.0", "CC-BY-SA-3.0",
+	"CC-BY-SA-4.0", "CC-PDDC", "CC0-1.0", "CDDL-1.0", "CDDL-1.1",
+	"CECILL-2.1", "CPL-1.0", "curl", "ECL-2.0", "EFL-2.0", "Elastic-2.0",
+	"EPL-1.0", "EPL-2.0", "EUPL-1.1", "EUPL-1.2", "FSFAP", "FTL",
+	"GFDL-1.3", "GFDL-1.3-only", "GFDL-1.3-or-later", "GPL-1.0",
+	"GPL-1.0+", "GPL-1.0-only", "GPL-1.0-or-later", "GPL-2.0", "GPL-2.0+",
+	"GPL-2.0-only", "GPL-2.0-or-later", "GPL-2.0-with-classpath-exception",
+	"GPL-3.0", "GPL-3.0+", "GPL-3.0-only", "GPL-3.0-or-later", "HPND",
+	"ICU", "IJG", "Intel", "Intel-ACPI", "ISC", "LGPL-2.0", "LGPL-2.0+",
+	"LGPL-2.0-only", "LGPL-2.0-or-later", "LGPL-2.1", "LGPL-2.1+",
+	"LGPL-2.1-only", "LGPL-2.1-or-later", "LGPL-3.0", "LGPL-3.0+",
+	"LGPL-3.0-only", "LGPL-3.0-or-later", "Libpng", "libpng-2.0", "MirOS",
+	"MIT", "MIT-0", "MIT-CMU", "MPL-1.1", "MPL-2.0",
+	"MPL-2.0-no-copyleft-exception", "MS-PL", "MS-RL", "NCSA", "NTP",
+	"OFL-1.1", "OLDAP-2.8", "OpenSSL", "OSL-3.0", "PHP-3.01", "PostgreSQL",
+	"PSF-2.0", "Python-2.0", "Ruby", "SGI-B-2.0", "SISSL", "Sleepycat",
+	"SMLNJ", "SSPL-1.0", "Unicode-3.0", "Unicode-DFS-2016", "Unlicense",
+	"UPL-1.0", "Vim", "W3C", "WTFPL", "X11", "Xnet", "Zlib",
+	"zlib-acknowledgement", "ZPL-2.0", "ZPL-2.1",
+}
+
+// knownSPDXLicenses maps the lower case license identifiers to their
+// canonical spelling, SPDX matches identifiers case insensitively.
+var knownSPDXLicenses = func() map[string]string {
+	m := map[string]string{}
+	for _, id := range spdxLicenseIDs {
+		m[strings.ToLower(id)] = id
+	}
+	return m
+}()
@@ -0,0 +1,261 @@
+package uswid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultWindowSize is the size of the window a Scanner searches for magic
+// values if no other size is given.
+const DefaultWindowSize = 1 << 20
+
+// minWindowSize is the smallest window which can hold every possible header
+// (the header size is stored as 16 bit value).
+const minWindowSize = 1 << 16
+
+// Scanner finds uSWID blobs in a stream without reading it into memory as a
+// whole. Only a window of the input is kept in memory while searching, the
+// payload of every blob found is read into its own buffer. Magic values
+// spanning two windows are found as well.
+//
+// Use it like a bufio.Scanner:
+//
+//	s := uswid.NewScanner(r)
+//	for s.Next() {
+//		b := s.Blob()
+//		...
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+type Scanner struct {
+	r          io.Reader
+	buf        []byte
+	start, end int   // unsearched data in buf
+	offset     int64 // offset of buf[0] in the input
+	eof        bool
+	blob       Blob
+	ids        UswidSoftwareIdentity // decoded payload of blob
+	found      bool                  // whether a valid blob was found
+	skipErr    error                 // why the first invalid blob was skipped
+	err        error
+}
+
+// NewScanner returns a Scanner reading from r using DefaultWindowSize.
+func NewScanner(r io.Reader) *Scanner {
+	return NewScannerSize(r, DefaultWindowSize)
+}
+
+// NewScannerSize returns a Scanner reading from r, which keeps windowSize
+// bytes of the input in memory. windowSize is raised to 64 KiB if smaller.
+func NewScannerSize(r io.Reader, windowSize int) *Scanner {
+	if windowSize < minWindowSize {
+		windowSize = minWindowSize
+	}
+	return &Scanner{r: r, buf: make([]byte, windowSize)}
+}
+
+// NewScannerAt returns a Scanner reading the first size bytes of r.
+func NewScannerAt(r io.ReaderAt, size int64) *Scanner {
+	return NewScanner(io.NewSectionReader(r, 0, size))
+}
+
+// fill moves the unsearched data to the start of the window and reads more
+// data behind it. It returns false if no data could be added.
+func (s *Scanner) fill() bool {
+	if s.eof || s.err != nil {
+		return false
+	}
+	if s.start > 0 {
+		copy(s.buf, s.buf[s.start:s.end])
+		s.offset += int64(s.start)
+		s.end -= s.start
+		s.start = 0
+	}
+	if s.end == len(s.buf) {
+		return false
+	}
+	// like bufio, give up on readers returning neither data nor an error
+	for i := 0; i < 100; i++ {
+		n, err := s.r.Read(s.buf[s.end:])
+		s.end += n
+		if err == io.EOF {
+			s.eof = true
+			return n > 0
+		}
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if n > 0 {
+			return true
+		}
+	}
+	s.err = io.ErrNoProgress
+	return false
+}
+
+// need makes sure that n bytes starting at s.start are in the window.
+func (s *Scanner) need(n int) bool {
+	for s.end-s.start < n {
+		if !s.fill() {
+			return false
+		}
+	}
+	return true
+}
+
+// Next advances to the next uSWID blob, which is then available through
+// Blob. It returns false at the end of the input or if an error occurred.
+// Like Scan, it skips magic values which turn out not to start a valid blob.
+func (s *Scanner) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	for {
+		i := bytes.Index(s.buf[s.start:s.end], magic)
+		if i != -1 {
+			s.start += i
+			err := s.readBlob()
+			if err == nil {
+				s.found = true
+				return true
+			}
+			if s.err != nil {
+				// reading the input failed, there is no point in going on
+				s.err = fmt.Errorf("uSWID data at offset %#x: %w", s.blob.Offset, s.err)
+				return false
+			}
+			if s.skipErr == nil {
+				s.skipErr = fmt.Errorf("uSWID data at offset %#x: %w", s.blob.Offset, err)
+			}
+			continue
+		}
+		// keep the tail, the next magic value might start in there
+		if s.end-s.start >= len(magic) {
+			s.start = s.end - (len(magic) - 1)
+		}
+		if !s.fill() {
+			if !s.found && s.err == nil {
+				s.err = s.skipErr
+			}
+			return false
+		}
+	}
+}
+
+// readBlob reads the blob starting at s.start. If it turns out not to be a
+// valid blob, the scanner is set up to continue right after its magic value.
+func (s *Scanner) readBlob() error {
+	s.blob = Blob{Offset: int(s.offset) + s.start}
+	s.ids = UswidSoftwareIdentity{}
+	if !s.need(headerSizeV1) {
+		s.start++
+		return s.readErr(ErrTruncatedHeader)
+	}
+	h, err := ParseHeader(s.buf[s.start:s.end])
+	if errors.Is(err, ErrTruncatedHeader) {
+		// the header might just not be in the window completely yet
+		if !s.need(int(h.Size)) {
+			s.start++
+			return s.readErr(ErrTruncatedHeader)
+		}
+		h, err = ParseHeader(s.buf[s.start:s.end])
+	}
+	s.blob.Header = h
+	if err != nil {
+		s.start++
+		return err
+	}
+	start := s.start
+	s.start += int(h.Size)
+	s.blob.PayloadOffset = int(s.offset) + s.start
+
+	// copy what is already in the window, read the rest directly. The buffer
+	// grows while reading, so a bogus payload size can't make us allocate
+	// more than the input actually holds.
+	var payload bytes.Buffer
+	n := int(h.PayloadSize)
+	if s.end-s.start < n {
+		n = s.end - s.start
+	}
+	payload.Write(s.buf[s.start : s.start+n])
+	s.start += n
+	if rest := int64(h.PayloadSize) - int64(n); rest > 0 {
+		// the window is dropped, keep the header in case the data has to be
+		// searched again
+		header := append([]byte{}, s.buf[start:start+int(h.Size)]...)
+		read, err := io.CopyN(&payload, s.r, rest)
+		s.offset += int64(s.end) + read
+		s.start, s.end = 0, 0
+		if err == io.EOF {
+			s.eof = true
+			err = fmt.Errorf("%w: %d bytes payload, %d bytes left", ErrPayloadExceedsBlob, h.PayloadSize, int64(n)+read)
+		} else if err != nil {
+			s.err = err
+			return err
+		}
+		if err == nil {
+			s.blob.Payload = payload.Bytes()
+			err = s.ids.FromBlob(s.blob)
+		}
+		if err != nil {
+			s.replay(append(header[1:], payload.Bytes()...))
+			return err
+		}
+		return nil
+	}
+	s.blob.Payload = payload.Bytes()
+	if err := s.ids.FromBlob(s.blob); err != nil {
+		s.start = start + 1
+		return err
+	}
+	return nil
+}
+
+// replay makes the scanner read data again, which was read from the input
+// after the magic value of the current blob.
+func (s *Scanner) replay(data []byte) {
+	s.r = io.MultiReader(bytes.NewReader(data), s.r)
+	s.offset = int64(s.blob.Offset) + 1
+	s.start, s.end = 0, 0
+	s.eof = false
+}
+
+// readErr returns the read error if there was one, otherwise err.
+func (s *Scanner) readErr(err error) error {
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+// Blob returns the blob found by the last call to Next.
+func (s *Scanner) Blob() Blob {
+	return s.blob
+}
+
+// Err returns the first error that occurred while scanning.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// FromReader decodes all uSWID blobs found in r and returns them. In contrast
+// to FromImage the input is not read into memory as a whole.
+func (uswid *UswidSoftwareIdentity) FromReader(r io.Reader) ([]Blob, error) {
+	var blobs []Blob
+	s := NewScanner(r)
+	for s.Next() {
+		uswid.Identities = append(uswid.Identities, s.ids.Identities...)
+		blobs = append(blobs, s.Blob())
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(blobs) == 0 {
+		return nil, ErrNotFound
+	}
+	return blobs, nil
+}
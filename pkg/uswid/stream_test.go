@@ -0,0 +1,88 @@
+package uswid
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+// syntheticImage is an erased flash image of size bytes with a uSWID blob
+// every interval bytes. It is generated while reading, so the image itself
+// takes no memory.
+type syntheticImage struct {
+	blob     []byte
+	size     int64
+	interval int64
+	pos      int64
+}
+
+func (s *syntheticImage) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if rest := s.size - s.pos; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	for i := 0; i < len(p); {
+		o := (s.pos + int64(i)) % s.interval
+		var n int
+		if o < int64(len(s.blob)) {
+			n = copy(p[i:], s.blob[o:])
+		} else {
+			n = len(p) - i
+			if next := s.interval - o; int64(n) > next {
+				n = int(next)
+			}
+			for j := i; j < i+n; j++ {
+				p[j] = 0xff
+			}
+		}
+		i += n
+	}
+	s.pos += int64(len(p))
+	return len(p), nil
+}
+
+func TestScannerSyntheticImage(t *testing.T) {
+	img := &syntheticImage{blob: readFixture(t, "v2-zlib.uswid"), size: 8 << 20, interval: 1<<20 + 7}
+	s := NewScanner(img)
+	n := 0
+	for s.Next() {
+		if want := n * (1<<20 + 7); s.Blob().Offset != want {
+			t.Errorf("blob %d at %#x, want %#x", n, s.Blob().Offset, want)
+		}
+		n++
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 8 {
+		t.Errorf("found %d blobs, want 8", n)
+	}
+}
+
+// BenchmarkScanner scans images of growing size holding four blobs each. The
+// bytes allocated per scan only depend on the window size and the blobs
+// found, so they stay the same for all sizes.
+func BenchmarkScanner(b *testing.B) {
+	blob := readFixture(b, "v2-zlib.uswid")
+	for _, size := range []int64{16 << 20, 256 << 20, 1 << 30} {
+		b.Run(fmt.Sprintf("%dMiB", size>>20), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				s := NewScanner(&syntheticImage{blob: blob, size: size, interval: size / 4})
+				n := 0
+				for s.Next() {
+					n++
+				}
+				if err := s.Err(); err != nil {
+					b.Fatal(err)
+				}
+				if n != 4 {
+					b.Fatalf("found %d blobs, want 4", n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package uswid
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/9elements/goswid/pkg/uefi"
+	"github.com/CodingVoid/swid"
+)
+
+// FFSBlob is a uSWID blob found in a file of an UEFI firmware volume. The
+// offsets of the blob are relative to the (decompressed) section holding it.
+type FFSBlob struct {
+	Blob
+	File       uefi.GUID // name of the FFS file holding the blob
+	Identities []swid.SoftwareIdentity
+}
+
+// FromUEFI decodes the uSWID data stored in the FFS files of all UEFI
+// firmware volumes in the first size bytes of r. Compressed sections are
+// decompressed and UEFI capsules are unwrapped. The blobs are returned along
+// with the GUID of the file owning them and the identities decoded from them.
+// If there is no uSWID data, no error is returned.
+func (uswid *UswidSoftwareIdentity) FromUEFI(r io.ReaderAt, size int64) ([]FFSBlob, error) {
+	volumes, err := uefiVolumes(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var found []FFSBlob
+	err = uefi.Walk(volumes, func(f *uefi.File, data []byte) error {
+		blobs, err := Scan(data)
+		if err != nil {
+			return fmt.Errorf("FFS file %s: %w", f.Name, err)
+		}
+		for _, b := range blobs {
+			n := len(uswid.Identities)
+			if err := uswid.FromBlob(b); err != nil {
+				return fmt.Errorf("FFS file %s: uSWID data at offset %#x: %w", f.Name, b.Offset, err)
+			}
+			found = append(found, FFSBlob{Blob: b, File: f.Name, Identities: uswid.Identities[n:]})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// uefiVolumes returns the firmware volumes in the first size bytes of r, or
+// in the firmware images of the capsule r holds.
+func uefiVolumes(r io.ReaderAt, size int64) ([]uefi.Volume, error) {
+	images := []uefi.CapsuleImage{{Size: size}}
+	capsule, err := uefi.ParseCapsule(r, size)
+	if err == nil {
+		images = capsule.Images
+	} else if !errors.Is(err, uefi.ErrNotCapsule) {
+		return nil, err
+	}
+
+	var volumes []uefi.Volume
+	for _, img := range images {
+		found, err := uefi.FindVolumes(io.NewSectionReader(r, img.Offset, img.Size), img.Size)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, found...)
+	}
+	return volumes, nil
+}
@@ -0,0 +1,120 @@
+package uswid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/9elements/goswid/pkg/uefi"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+var (
+	plainFileGUID = uefi.GUID{0x11, 0x11, 0x11, 0x11, 1}
+	lzmaFileGUID  = uefi.GUID{0x22, 0x22, 0x22, 0x22, 2}
+)
+
+func uefiSection(typ uefi.SectionType, data []byte) []byte {
+	size := 4 + len(data)
+	s := append([]byte{byte(size), byte(size >> 8), byte(size >> 16), byte(typ)}, data...)
+	for len(s)%4 != 0 {
+		s = append(s, 0)
+	}
+	return s
+}
+
+func uefiLZMASection(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := lzma.WriterConfig{Size: int64(len(data))}.NewWriter(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	header := make([]byte, 20)
+	copy(header, uefi.LZMACustomDecompressGUID[:])
+	binary.LittleEndian.PutUint16(header[16:], 24)
+	binary.LittleEndian.PutUint16(header[18:], 1) // processing required
+	return uefiSection(uefi.SectionGUIDDefined, append(header, b.Bytes()...))
+}
+
+// uefiVolume returns an FFS2 firmware volume of erase polarity 0 holding a
+// driver file for every name in files.
+func uefiVolume(names []uefi.GUID, files [][]byte) []byte {
+	v := make([]byte, 72)
+	copy(v[16:], uefi.FFS2GUID[:])
+	copy(v[40:], "_FVH")
+	binary.LittleEndian.PutUint16(v[48:], 72)
+	for i, data := range files {
+		f := make([]byte, 24)
+		copy(f, names[i][:])
+		size := 24 + len(data)
+		f[18] = byte(uefi.FileTypeDriver)
+		f[20], f[21], f[22] = byte(size), byte(size>>8), byte(size>>16)
+		f[23] = 0x07 // data valid
+		v = append(append(v, f...), data...)
+		for len(v)%8 != 0 {
+			v = append(v, 0)
+		}
+	}
+	v = append(v, make([]byte, 24)...)
+	binary.LittleEndian.PutUint64(v[32:], uint64(len(v)))
+	var sum uint16
+	for i := 0; i < 72; i += 2 {
+		sum += binary.LittleEndian.Uint16(v[i:])
+	}
+	binary.LittleEndian.PutUint16(v[50:], -sum)
+	return v
+}
+
+func TestFromUEFI(t *testing.T) {
+	fv := uefiVolume([]uefi.GUID{plainFileGUID, lzmaFileGUID}, [][]byte{
+		uefiSection(uefi.SectionRaw, testBlob(t, "plain")),
+		append(uefiSection(uefi.SectionUserInterface, []byte("S\x00B\x00O\x00M\x00\x00\x00")),
+			uefiLZMASection(t, uefiSection(uefi.SectionRaw, testBlob(t, "compressed")))...),
+	})
+	capsule := make([]byte, 28)
+	copy(capsule, uefi.CapsuleGUID[:])
+	binary.LittleEndian.PutUint32(capsule[16:], 28)
+	binary.LittleEndian.PutUint32(capsule[24:], uint32(28+len(fv)))
+	capsule = append(capsule, fv...)
+
+	for name, image := range map[string][]byte{
+		"volume":  append(bytes.Repeat([]byte{0xff}, 0x100), fv...),
+		"capsule": capsule,
+	} {
+		var u UswidSoftwareIdentity
+		blobs, err := u.FromUEFI(bytes.NewReader(image), int64(len(image)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var files []uefi.GUID
+		for _, b := range blobs {
+			files = append(files, b.File)
+		}
+		if want := []uefi.GUID{plainFileGUID, lzmaFileGUID}; !reflect.DeepEqual(files, want) {
+			t.Errorf("%s: owning files = %v, want %v", name, files, want)
+		}
+		if got, want := softwareNames(u), []string{"plain", "compressed"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: software names = %v, want %v", name, got, want)
+		}
+		for i, b := range blobs {
+			if len(b.Identities) != 1 || b.Identities[0].SoftwareName != softwareNames(u)[i] {
+				t.Errorf("%s: identities of blob %d = %+v", name, i, b.Identities)
+			}
+		}
+
+		// the image codec falls back to the firmware volumes
+		var img UswidSoftwareIdentity
+		if err := img.Decode(bytes.NewReader(image), CodecOptions{Format: "image"}); err != nil {
+			t.Fatalf("%s: Decode: %v", name, err)
+		}
+		if got := softwareNames(img); !reflect.DeepEqual(got, softwareNames(u)) {
+			t.Errorf("%s: Decode found %v", name, got)
+		}
+	}
+}
@@ -2,15 +2,13 @@ package uswid
 
 import (
 	"bytes"
-	"compress/zlib"
-	"encoding/binary"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
-	"io/ioutil"
+	"os"
 
 	"github.com/fxamacker/cbor/v2"
 	"github.com/CodingVoid/swid"
@@ -18,7 +16,6 @@ import (
 )
 
 var magic []byte = []byte{0x53, 0x42, 0x4F, 0x4D, 0xD6, 0xBA, 0x2E, 0xAC, 0xA3, 0xE6, 0x7A, 0x52, 0xAA, 0xEE, 0x3B, 0xAF} // can't be const...
-const flagCompressZlib = 0x01
 
 // uSWID is essentially supposed to be a collection of CoSWID/SWID tags.
 type UswidSoftwareIdentity struct {
@@ -113,48 +110,43 @@ func jsonMinify(json string, stripSpace bool) string {
 	return out.String()
 }
 
+// FromFile decodes the tags in the file at filepath. The format of the file
+// is detected from its content.
 func (uswid *UswidSoftwareIdentity) FromFile(filepath string) error {
-	inputFile, err := ioutil.ReadFile(filepath)
+	return uswid.FromFileOptions(filepath, CodecOptions{})
+}
+
+// FromFileOptions is like FromFile, but opts controls how the file is read.
+func (uswid *UswidSoftwareIdentity) FromFileOptions(filepath string, opts CodecOptions) error {
+	f, err := os.Open(filepath)
 	if err != nil {
 		return err
 	}
-
-	/* check file extension of input file */
-	ifParts := strings.Split(filepath, ".")
-	switch ifParts[len(ifParts)-1] {
-	case "pc":
-		pcStr := strings.ReplaceAll(string(inputFile), "\r\n", "\n") // replace windows line endings with line feeds
-		err = uswid.FromPC(pcStr, filepath)
-	case "json":
-		jsonStr := jsonMinify(string(inputFile), false)
-		err = uswid.FromJSON(jsonStr)
-	case "xml":
-		err = uswid.FromXML(string(inputFile))
-	case "cbor":
-		err = uswid.FromCBOR(inputFile, false)
-	case "uswid":
-		fallthrough
-	default:
-		_, err = uswid.FromUSWID(inputFile)
-	}
-	if err != nil {
+	defer f.Close()
+	opts.Filename = filepath
+	if err := uswid.Decode(f, opts); err != nil {
 		return fmt.Errorf("parsing %s: %w", filepath, err)
 	}
 	return nil
 }
 
+// FromCBOR decodes all CoSWID tags in blob, which is zlib compressed if
+// compressed is set.
 func (uswid *UswidSoftwareIdentity) FromCBOR(blob []byte, compressed bool) error {
-	buf := bytes.NewBuffer(blob)
-	var decoder *cbor.Decoder
 	if compressed {
-		rd, err := zlib.NewReader(buf)
-		if err != nil {
-			return fmt.Errorf("create zlib reader: %w", err)
-		}
-		decoder = cbor.NewDecoder(rd)
-	} else {
-		decoder = cbor.NewDecoder(buf)
+		return uswid.FromCBORCompression(blob, CompressionZlib)
 	}
+	return uswid.FromCBORCompression(blob, CompressionNone)
+}
+
+// FromCBORCompression decodes all CoSWID tags in blob, which is compressed
+// with the given compression type.
+func (uswid *UswidSoftwareIdentity) FromCBORCompression(blob []byte, compression Compression) error {
+	rd, err := decompressReader(blob, compression)
+	if err != nil {
+		return err
+	}
+	decoder := cbor.NewDecoder(rd)
 	for {
 		var id swid.SoftwareIdentity
 		err := decoder.Decode(&id)
@@ -169,38 +161,33 @@ func (uswid *UswidSoftwareIdentity) FromCBOR(blob []byte, compressed bool) error
 	return nil
 }
 
-// returns the offset where the uswid data was found (first byte) in blob
+// FromUSWID decodes the first uSWID blob found in blob and returns the offset
+// where the uswid data was found (first byte). Use FromImage to decode all of
+// them.
 func (uswid *UswidSoftwareIdentity) FromUSWID(blob []byte) (int, error) {
-	var err error
-	offset := bytes.Index(blob, magic)
-	if offset == -1 {
-		return -1, errors.New("could not find uswid data")
-	}
-	headerVersion := blob[offset+16]
-	if headerVersion != 2 {
-		return offset, errors.New("no known header version")
-	}
-	//header_len := binary.LittleEndian.Uint16(blob[offset+17:offset+19])
-	payloadLen := binary.LittleEndian.Uint32(blob[offset+19 : offset+23])
-	flags := blob[offset+23]
-	if (flags & flagCompressZlib) != 0 {
-		err = uswid.FromCBOR(blob[offset+24:uint32(offset)+24+payloadLen], true)
-	} else {
-		err = uswid.FromCBOR(blob[offset+24:uint32(offset)+24+payloadLen], false)
-	}
+	offset := -1
+	err := scan(blob, func(b Blob) error {
+		if err := uswid.fromBlob(b); err != nil {
+			return err
+		}
+		offset = b.Offset
+		return errStopScan
+	})
 	if err != nil {
-		return -1, fmt.Errorf("extract CBOR: %w", err)
+		return -1, err
+	}
+	if offset == -1 {
+		return -1, ErrNotFound
 	}
 	return offset, nil
 }
 
 func (uswid *UswidSoftwareIdentity) FromJSON(jsonStr string) error {
+	jsonStr = strings.TrimSpace(jsonStr)
 	if len(jsonStr) == 0 {
 		return errors.New("input data empty")
 	}
 
-	jsonStr = strings.TrimSpace(jsonStr)
-
 	if jsonStr[0] == '[' && jsonStr[len(jsonStr)-1] == ']' {
 		var uswidID UswidSoftwareIdentity
 		if err := json.Unmarshal([]byte(jsonStr), &uswidID.Identities); err != nil {
@@ -232,6 +219,7 @@ func (uswid *UswidSoftwareIdentity) FromXML(xmlStr string) error {
 			}
 			return err
 		}
+		fromXMLRels(&id)
 		uswid.Identities = append(uswid.Identities, id)
 		offset += xmlDecoder.InputOffset()
 	}
@@ -267,22 +255,46 @@ func (uswid *UswidSoftwareIdentity) FromPC(pcData string, filename string) error
 	return nil
 }
 
-func (uswid UswidSoftwareIdentity) ToUSWID(compress bool) ([]byte, error) {
-	var header [16 + 1 + 2 + 4 + 1]byte
-	copy(header[:16], magic)                         // magic USWID value
-	header[16] = 2                                   // header version
-	binary.LittleEndian.PutUint16(header[17:19], 24) // header size
+// USWIDOptions control how ToUSWIDOptions and the uswid codec encode uSWID
+// blobs. The cbor codec uses Compression only.
+type USWIDOptions struct {
+	// HeaderVersion is 2 or 3. 0 selects DefaultHeaderVersion, or version 3
+	// if Compression needs it.
+	HeaderVersion uint8
+	Compression   Compression
+}
 
-	header[23] = 0x00 // flags
+// ToUSWID encodes all identities as uSWID blob with a version 2 header. The
+// payload is zlib compressed if compress is set.
+func (uswid UswidSoftwareIdentity) ToUSWID(compress bool) ([]byte, error) {
+	opts := USWIDOptions{HeaderVersion: 2}
 	if compress {
-		header[23] |= 0x01
+		opts.Compression = CompressionZlib
+	}
+	return uswid.ToUSWIDOptions(opts)
+}
+
+// ToUSWIDOptions encodes all identities as uSWID blob using the header
+// version and payload compression of opts. Version 2 headers can only
+// express zlib compression.
+func (uswid UswidSoftwareIdentity) ToUSWIDOptions(opts USWIDOptions) ([]byte, error) {
+	headerVersion := opts.HeaderVersion
+	if headerVersion == 0 {
+		headerVersion = headerVersionFor(opts.Compression)
+	}
+	cborBuf, err := uswid.ToCBORCompression(opts.Compression)
+	if err != nil {
+		return nil, err
 	}
-	cborBuf, err := uswid.ToCBOR(compress)
+	h, err := NewHeader(headerVersion, opts.Compression, uint32(len(cborBuf)))
 	if err != nil {
 		return nil, err
 	}
-	binary.LittleEndian.PutUint32(header[19:23], uint32(len(cborBuf)))
-	return append(header[:], cborBuf...), nil
+	header, err := h.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(header, cborBuf...), nil
 }
 
 func (uswid UswidSoftwareIdentity) ToJSON() ([]byte, error) {
@@ -308,11 +320,51 @@ func (uswid UswidSoftwareIdentity) ToJSON() ([]byte, error) {
 	return jsonBuf, nil
 }
 
+// xmlRels are the rel values which the XML schema spells differently than the
+// swid package, which puts spaces into them.
+var xmlRels = map[int64]string{
+	swid.RelInstallationMedia: "installationmedia",
+	swid.RelPackageInstaller:  "packageinstaller",
+	swid.RelSeeAlso:           "see-also",
+}
+
+// toXMLRels replaces the rel values of the links of id with their XML
+// spelling. The links are copied, so the caller's identity is not modified.
+func toXMLRels(id *swid.SoftwareIdentity) {
+	if id.Links == nil {
+		return
+	}
+	links := append(swid.Links{}, *id.Links...)
+	for i, l := range links {
+		for code, name := range xmlRels {
+			if l.Rel.String() == swid.NewRel(code).String() {
+				links[i].Rel = *swid.NewRel(name)
+			}
+		}
+	}
+	id.Links = &links
+}
+
+// fromXMLRels turns rel values spelled the XML way into their code points.
+func fromXMLRels(id *swid.SoftwareIdentity) {
+	if id.Links == nil {
+		return
+	}
+	for i, l := range *id.Links {
+		for code, name := range xmlRels {
+			if l.Rel.String() == name {
+				(*id.Links)[i].Rel = *swid.NewRel(code)
+			}
+		}
+	}
+}
+
 func (uswid UswidSoftwareIdentity) ToXML() ([]byte, error) {
 	var xmlBuf []byte
 	for _, id := range uswid.Identities {
-		id.XMLName.Space = "http://standards.iso.org/iso/19770/-2/2015/schema.xsd"
+		id.XMLName.Space = SWIDNamespace
 		id.XMLName.Local = "SoftwareIdentity"
+		toXMLRels(&id)
 
 		buf, err := id.ToXML()
 		if err != nil {
@@ -323,7 +375,18 @@ func (uswid UswidSoftwareIdentity) ToXML() ([]byte, error) {
 	return xmlBuf, nil
 }
 
+// ToCBOR encodes all identities as concatenated CoSWID tags, which are zlib
+// compressed if compress is set.
 func (uswid UswidSoftwareIdentity) ToCBOR(compress bool) ([]byte, error) {
+	if compress {
+		return uswid.ToCBORCompression(CompressionZlib)
+	}
+	return uswid.ToCBORCompression(CompressionNone)
+}
+
+// ToCBORCompression encodes all identities as concatenated CoSWID tags and
+// compresses the result with the given compression type.
+func (uswid UswidSoftwareIdentity) ToCBORCompression(compression Compression) ([]byte, error) {
 	var cborBuf []byte
 	for _, id := range uswid.Identities {
 		buf, err := id.ToCBOR()
@@ -332,16 +395,5 @@ func (uswid UswidSoftwareIdentity) ToCBOR(compress bool) ([]byte, error) {
 		}
 		cborBuf = append(cborBuf, buf...)
 	}
-	if compress {
-		var buf bytes.Buffer
-		zlibWriter := zlib.NewWriter(&buf)
-		_, err := zlibWriter.Write(cborBuf)
-		if err != nil {
-			return nil, fmt.Errorf("cannot zlib compress CBOR data: %w", err)
-		}
-		zlibWriter.Close()
-		return buf.Bytes(), nil
-	} else {
-		return cborBuf, nil
-	}
+	return compress(cborBuf, compression)
 }
@@ -0,0 +1,89 @@
+package uswid
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var jsonSeeds = []string{
+	`{"tag-id":"acbd18db-4cc2-f85c-edef-654fccc4a4d8","software-name":"foo","software-version":"1.0","entity":[{"entity-name":"ACME","role":["tag-creator","software-creator"]}]}`,
+	`[ {"tag-id":"foo","software-name":"foo"}, {"tag-id":"bar","software-name":"bar"} ]`,
+	"{\n  // the tag\n  \"tag-id\": \"foo\", /* name */ \"software-name\": \"a \\\"quoted\\\" name\"\n}",
+	`{"software-name":"back\\slash // no comment"}`,
+	`[]`,
+	``,
+}
+
+func FuzzFromUSWID(f *testing.F) {
+	for _, name := range []string{"v1.uswid", "v2-zlib.uswid", "v3-lzma.uswid", "mixed.rom"} {
+		data := readFixture(f, name)
+		f.Add(data)
+		f.Add(data[:len(data)/2])
+	}
+	f.Add(append(append([]byte{}, magic...), 9))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var u UswidSoftwareIdentity
+		offset, err := u.FromUSWID(data)
+		if err == nil && (offset < 0 || offset >= len(data)) {
+			t.Errorf("FromUSWID returned offset %d for %d bytes", offset, len(data))
+		}
+		var all UswidSoftwareIdentity
+		if _, err := all.FromImage(data); err == nil && len(all.Identities) < len(u.Identities) {
+			t.Errorf("FromImage decoded %d identities, FromUSWID %d", len(all.Identities), len(u.Identities))
+		}
+	})
+}
+
+func FuzzFromCBOR(f *testing.F) {
+	for _, name := range []string{"v1.uswid", "v2-zlib.uswid", "v3-lzma.uswid"} {
+		data := readFixture(f, name)
+		h, err := ParseHeader(data)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data[h.Size:], uint8(h.Compression))
+	}
+	f.Add([]byte{0xa0}, uint8(CompressionNone))
+	f.Add([]byte{}, uint8(CompressionLZMA))
+	f.Fuzz(func(t *testing.T, data []byte, compression uint8) {
+		var u UswidSoftwareIdentity
+		u.FromCBORCompression(data, Compression(compression))
+	})
+}
+
+func FuzzFromJSON(f *testing.F) {
+	for _, s := range jsonSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		var u UswidSoftwareIdentity
+		u.FromJSON(jsonMinify(s, false))
+	})
+}
+
+func FuzzJsonMinify(f *testing.F) {
+	for _, s := range jsonSeeds {
+		f.Add(s, false)
+		f.Add(s, true)
+	}
+	f.Fuzz(func(t *testing.T, s string, stripSpace bool) {
+		out := jsonMinify(s, stripSpace)
+		if len(out) > len(s) && utf8.ValidString(s) {
+			t.Errorf("jsonMinify(%q) = %q grew", s, out)
+		}
+		// JSON without comments only loses its whitespace
+		if !stripSpace || strings.Contains(s, "/") || !utf8.ValidString(s) || !json.Valid([]byte(s)) {
+			return
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		if out != compact.String() {
+			t.Errorf("jsonMinify(%q) = %q, want %q", s, out, compact.String())
+		}
+	})
+}
@@ -0,0 +1,331 @@
+package uswid
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/CodingVoid/swid"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Violation is a breach of the CoSWID rules of RFC 9393 found by Validate.
+type Violation struct {
+	// Path names the offending field with the CoSWID JSON keys, e.g.
+	// "[0].entity[1].role" for the roles of the second entity of the first
+	// identity.
+	Path    string
+	Message string
+	// Line and Column locate violations found in XML documents, they are 0
+	// if unknown.
+	Line   int
+	Column int
+}
+
+func (v Violation) String() string {
+	switch {
+	case v.Line != 0 && v.Column != 0:
+		return fmt.Sprintf("%d:%d: %s: %s", v.Line, v.Column, v.Path, v.Message)
+	case v.Line != 0:
+		return fmt.Sprintf("%d: %s: %s", v.Line, v.Path, v.Message)
+	}
+	return v.Path + ": " + v.Message
+}
+
+// code points registered by RFC 9393 for the values which are either an
+// integer or a text
+var (
+	validRoles = map[int64]bool{
+		swid.RoleTagCreator:      true,
+		swid.RoleSoftwareCreator: true,
+		swid.RoleAggregator:      true,
+		swid.RoleDistributor:     true,
+		swid.RoleLicensor:        true,
+		swid.RoleMaintainer:      true,
+	}
+	validRels = map[int64]bool{
+		swid.RelLicense:           true,
+		swid.RelCompiler:          true,
+		swid.RelAncestor:          true,
+		swid.RelComponent:         true,
+		swid.RelFeature:           true,
+		swid.RelInstallationMedia: true,
+		swid.RelPackageInstaller:  true,
+		swid.RelParent:            true,
+		swid.RelPatches:           true,
+		swid.RelRequires:          true,
+		swid.RelSeeAlso:           true,
+		swid.RelSupersedes:        true,
+		swid.RelSupplemental:      true,
+	}
+	validVersionSchemes = map[int64]bool{
+		swid.VersionSchemeMultipartNumeric:       true,
+		swid.VersionSchemeMultipartNumericSuffix: true,
+		swid.VersionSchemeAlphaNumeric:           true,
+		swid.VersionSchemeDecimal:                true,
+		swid.VersionSchemeSemVer:                 true,
+	}
+	validUses = map[int64]bool{
+		swid.UseOptional:    true,
+		swid.UseRequired:    true,
+		swid.UseRecommended: true,
+	}
+	validOwnerships = map[int64]bool{
+		swid.OwnershipAbandon: true,
+		swid.OwnershipPrivate: true,
+		swid.OwnershipShared:  true,
+	}
+)
+
+// Validate checks all identities against the CoSWID rules of RFC 9393 and
+// returns every violation found. Paths start with the index of the identity.
+func (uswid UswidSoftwareIdentity) Validate() []Violation {
+	var violations []Violation
+	for i, id := range uswid.Identities {
+		violations = append(violations, ValidateIdentity(id, fmt.Sprintf("[%d]", i))...)
+	}
+	return violations
+}
+
+// ValidateIdentity checks a single identity against the CoSWID rules of
+// RFC 9393. The paths of the violations start with path.
+func ValidateIdentity(id swid.SoftwareIdentity, path string) []Violation {
+	v := &validator{}
+	v.identity(id, path)
+	return v.violations
+}
+
+type validator struct {
+	violations []Violation
+}
+
+func (v *validator) add(path string, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) identity(id swid.SoftwareIdentity, path string) {
+	if tagID, err := id.TagID.MarshalCBOR(); err != nil || len(tagID) <= 1 {
+		// an unset tag-id encodes to null, an empty one to an empty string
+		v.add(path+".tag-id", "missing tag-id")
+	}
+	if id.SoftwareName == "" {
+		v.add(path+".software-name", "missing software-name")
+	}
+	if id.TagVersion < 0 {
+		v.add(path+".tag-version", "negative tag-version %d", id.TagVersion)
+	}
+	if n := btoi(id.Corpus) + btoi(id.Patch) + btoi(id.Supplemental); n > 1 {
+		v.add(path, "corpus, patch and supplemental are mutually exclusive")
+	}
+	if id.VersionScheme != nil {
+		if id.SoftwareVersion == "" {
+			v.add(path+".version-scheme", "version-scheme without software-version")
+		}
+		v.codePoint(path+".version-scheme", "version-scheme", id.VersionScheme, validVersionSchemes)
+	}
+
+	if len(id.Entities) == 0 {
+		v.add(path+".entity", "missing entity")
+	}
+	tagCreator := false
+	for i, e := range id.Entities {
+		if v.entity(e, fmt.Sprintf("%s.entity[%d]", path, i)) {
+			tagCreator = true
+		}
+	}
+	if len(id.Entities) > 0 && !tagCreator {
+		v.add(path+".entity", "no entity with role tag-creator")
+	}
+
+	if id.Links != nil {
+		for i, l := range *id.Links {
+			v.link(l, fmt.Sprintf("%s.link[%d]", path, i))
+		}
+	}
+	if id.Payload != nil {
+		v.resourceCollection(id.Payload.ResourceCollection, path+".payload")
+	}
+	if id.Evidence != nil {
+		v.resourceCollection(id.Evidence.ResourceCollection, path+".evidence")
+	}
+}
+
+// entity checks an entity and reports whether it has the tag-creator role.
+func (v *validator) entity(e swid.Entity, path string) bool {
+	if e.EntityName == "" {
+		v.add(path+".entity-name", "missing entity-name")
+	}
+	roles, err := codePoints(e.Roles)
+	if err != nil {
+		v.add(path+".role", "%v", err)
+	} else if len(roles) == 0 {
+		v.add(path+".role", "missing role")
+	}
+	tagCreator := false
+	for _, role := range roles {
+		v.checkCode(path+".role", "role", role, validRoles)
+		// the swid library keeps role names it doesn't know as text, like
+		// the RFC 9393 JSON name
+		if role == swid.RoleTagCreator || role == "tagCreator" || role == "tag-creator" {
+			tagCreator = true
+		}
+	}
+	if e.Thumbprint != nil {
+		v.hash(*e.Thumbprint, path+".thumbprint")
+	}
+	return tagCreator
+}
+
+func (v *validator) link(l swid.Link, path string) {
+	if l.Href == "" {
+		v.add(path+".href", "missing href")
+	}
+	v.codePoint(path+".rel", "rel", l.Rel, validRels)
+	if l.Ownership != nil {
+		v.codePoint(path+".ownership", "ownership", l.Ownership, validOwnerships)
+	}
+	if l.Use != nil {
+		v.codePoint(path+".use", "use", l.Use, validUses)
+	}
+}
+
+func (v *validator) resourceCollection(rc swid.ResourceCollection, path string) {
+	v.pathElements(rc.PathElements, path)
+	if rc.Processes != nil {
+		for i, p := range *rc.Processes {
+			if p.ProcessName == "" {
+				v.add(fmt.Sprintf("%s.process[%d].process-name", path, i), "missing process-name")
+			}
+		}
+	}
+	if rc.Resources != nil {
+		for i, r := range *rc.Resources {
+			if r.Type == "" {
+				v.add(fmt.Sprintf("%s.resource[%d].type", path, i), "missing type")
+			}
+		}
+	}
+}
+
+func (v *validator) pathElements(pe swid.PathElements, path string) {
+	if pe.Directories != nil {
+		for i, d := range *pe.Directories {
+			p := fmt.Sprintf("%s.directory[%d]", path, i)
+			if d.FsName == "" {
+				v.add(p+".fs-name", "missing fs-name")
+			}
+			if d.PathElements != nil {
+				v.pathElements(*d.PathElements, p+".path-elements")
+			}
+		}
+	}
+	if pe.Files != nil {
+		for i, f := range *pe.Files {
+			p := fmt.Sprintf("%s.file[%d]", path, i)
+			if f.FsName == "" {
+				v.add(p+".fs-name", "missing fs-name")
+			}
+			if f.Size != nil && *f.Size < 0 {
+				v.add(p+".size", "negative size %d", *f.Size)
+			}
+			if f.Hash != nil {
+				v.hash(*f.Hash, p+".hash")
+			}
+		}
+	}
+}
+
+func (v *validator) hash(h swid.HashEntry, path string) {
+	if err := swid.ValidHashEntry(h.HashAlgID, h.HashValue); err != nil {
+		v.add(path, "%v", err)
+	}
+}
+
+// codePoint checks a value which is either text or one of the integers in
+// valid.
+func (v *validator) codePoint(path string, name string, value cbor.Marshaler, valid map[int64]bool) {
+	codes, err := codePoints(value)
+	if err != nil {
+		v.add(path, "%v", err)
+		return
+	}
+	if len(codes) == 0 {
+		v.add(path, "missing %s", name)
+	}
+	for _, code := range codes {
+		v.checkCode(path, name, code, valid)
+	}
+}
+
+// checkCode reports integer code points which are not registered. Text
+// values and private use integers are always fine.
+func (v *validator) checkCode(path string, name string, code interface{}, valid map[int64]bool) {
+	switch c := code.(type) {
+	case int64:
+		switch {
+		case isPrivateUse(c):
+		case !inRegistryRange(c):
+			v.add(path, "%s %d out of range", name, c)
+		case !valid[c]:
+			v.add(path, "unregistered %s %d", name, c)
+		}
+	case string:
+		if c == "" {
+			v.add(path, "empty %s", name)
+		}
+	default:
+		v.add(path, "%s %v out of range", name, c)
+	}
+}
+
+// isPrivateUse reports whether c is in the range RFC 9393 reserves for
+// private use in all of its integer registries.
+func isPrivateUse(c int64) bool {
+	return c >= -256 && c <= -1
+}
+
+// inRegistryRange reports whether c can be registered in the integer
+// registries of RFC 9393.
+func inRegistryRange(c int64) bool {
+	return c >= 0 && c <= math.MaxUint32
+}
+
+// codePoints returns the values of role, rel, version-scheme, ownership and
+// use fields, which only expose them in their encodings. Integers are returned
+// as int64, integers beyond the int64 range as uint64.
+func codePoints(value cbor.Marshaler) ([]interface{}, error) {
+	data, err := value.MarshalCBOR()
+	if err != nil {
+		// unset values can't be encoded
+		return nil, nil
+	}
+	var decoded interface{}
+	if err := cbor.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	list, ok := decoded.([]interface{})
+	if !ok {
+		if decoded == nil {
+			return nil, nil
+		}
+		list = []interface{}{decoded}
+	}
+	for i, c := range list {
+		switch n := c.(type) {
+		case uint64:
+			if n <= math.MaxInt64 {
+				list[i] = int64(n)
+			}
+		case int64, string:
+		default:
+			return nil, fmt.Errorf("expected integer or text, got %T", c)
+		}
+	}
+	return list, nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
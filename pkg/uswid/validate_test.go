@@ -0,0 +1,66 @@
+package uswid
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func messages(violations []Violation) []string {
+	var out []string
+	for _, v := range violations {
+		out = append(out, v.String())
+	}
+	return out
+}
+
+func TestValidateCodePoints(t *testing.T) {
+	var u UswidSoftwareIdentity
+	err := u.FromJSON(`{"tag-id":"foo","software-name":"foo","entity":[{"entity-name":"ACME","role":["tag-creator",-1,-256,-257,300,4294967296]}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"[0].entity[0].role: role -257 out of range",
+		"[0].entity[0].role: unregistered role 300",
+		"[0].entity[0].role: role 4294967296 out of range",
+	}
+	if got := messages(u.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate = %q, want %q", got, want)
+	}
+}
+
+func TestValidateCBOR(t *testing.T) {
+	tag := map[interface{}]interface{}{
+		0:  "foo",
+		1:  "foo",
+		-5: "private use",
+		99: "unknown",
+		2: []interface{}{map[interface{}]interface{}{
+			31:       "ACME",
+			33:       1,
+			-300:     true,
+			"vendor": "extension",
+		}},
+	}
+	data, err := cbor.Marshal(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a second tag in the same payload
+	data = append(data, data...)
+	want := []string{
+		"[0].entity[0]: key -300 out of range",
+		"[0]: unregistered key 99",
+		"[1].entity[0]: key -300 out of range",
+		"[1]: unregistered key 99",
+	}
+	if got := messages(ValidateCBOR(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateCBOR = %q, want %q", got, want)
+	}
+
+	if got := ValidateUSWID(readFixture(t, "mixed.rom")); len(got) != 0 {
+		t.Errorf("ValidateUSWID of mixed.rom = %q, want no violations", messages(got))
+	}
+}
@@ -0,0 +1,143 @@
+package uswid
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// coswidKeys are the integer map keys RFC 9393 registered for CoSWID items,
+// with their names in CoSWID JSON.
+var coswidKeys = map[int64]string{
+	0: "tag-id", 1: "software-name", 2: "entity", 3: "evidence", 4: "link",
+	5: "software-meta", 6: "payload", 7: "hash", 8: "corpus", 9: "patch",
+	10: "media", 11: "supplemental", 12: "tag-version", 13: "software-version",
+	14: "version-scheme", 15: "lang", 16: "directory", 17: "file",
+	18: "process", 19: "resource", 20: "size", 21: "file-version", 22: "key",
+	23: "location", 24: "fs-name", 25: "root", 26: "path-elements",
+	27: "process-name", 28: "pid", 29: "type", 31: "entity-name",
+	32: "reg-id", 33: "role", 34: "thumbprint", 35: "date", 36: "device-id",
+	37: "artifact", 38: "href", 39: "ownership", 40: "rel", 41: "media-type",
+	42: "use", 43: "activation-status", 44: "channel-type",
+	45: "colloquial-version", 46: "description", 47: "edition",
+	48: "entitlement-data-required", 49: "entitlement-key", 50: "generator",
+	51: "persistent-id", 52: "product", 53: "product-family", 54: "revision",
+	55: "summary", 56: "unspsc-code", 57: "unspsc-version",
+}
+
+// ValidateCBOR checks the map keys of CBOR encoded CoSWID tags, like the
+// payload of a uSWID blob, against RFC 9393: integer keys have to be
+// registered or in the private use range, text keys are extensions. Decoding
+// drops the keys it doesn't know, so they can only be checked here. Paths
+// start with the index of the tag.
+func ValidateCBOR(data []byte) []Violation {
+	v := &validator{}
+	v.cbor(data, 0)
+	return v.violations
+}
+
+// ValidateUSWID checks the payloads of all uSWID blobs in data like
+// ValidateCBOR. The tags are counted across all blobs.
+func ValidateUSWID(data []byte) []Violation {
+	v := &validator{}
+	tags := 0
+	err := scan(data, func(b Blob) error {
+		rd, err := decompressReader(b.Payload, b.Header.Compression)
+		if err != nil {
+			return err
+		}
+		payload, err := ioutil.ReadAll(rd)
+		if err != nil {
+			return err
+		}
+		tags = v.cbor(payload, tags)
+		return nil
+	})
+	if err != nil {
+		v.add("", "%v", err)
+	}
+	return v.violations
+}
+
+// cbor checks the tags in data, numbering them from first on, and returns
+// the number of the tag following them.
+func (v *validator) cbor(data []byte, first int) int {
+	d := cbor.NewDecoder(bytes.NewReader(data))
+	i := first
+	for ; ; i++ {
+		var tag interface{}
+		err := d.Decode(&tag)
+		if err == io.EOF {
+			break
+		}
+		path := fmt.Sprintf("[%d]", i)
+		if err != nil {
+			v.add(path, "decoding cbor: %v", err)
+			break
+		}
+		if _, ok := tag.(map[interface{}]interface{}); !ok {
+			v.add(path, "expected map, got %T", tag)
+			continue
+		}
+		v.cborKeys(tag, path)
+	}
+	return i
+}
+
+// cborKeys checks the keys of all maps in value.
+func (v *validator) cborKeys(value interface{}, path string) {
+	switch val := value.(type) {
+	case []interface{}:
+		for i, e := range val {
+			v.cborKeys(e, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case map[interface{}]interface{}:
+		// report in a stable order, not the random map order
+		keys := make([]interface{}, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprintf("%T%v", keys[i], keys[i]) < fmt.Sprintf("%T%v", keys[j], keys[j])
+		})
+		for _, k := range keys {
+			e := val[k]
+			name := fmt.Sprint(k)
+			switch key := k.(type) {
+			case uint64:
+				if key > math.MaxInt64 {
+					v.add(path, "key %d out of range", key)
+					continue
+				}
+				name = v.cborKey(int64(key), path)
+			case int64:
+				name = v.cborKey(key, path)
+			case string:
+			default:
+				v.add(path, "expected integer or text key, got %T", k)
+				continue
+			}
+			v.cborKeys(e, path+"."+name)
+		}
+	}
+}
+
+// cborKey checks an integer key and returns its name for paths.
+func (v *validator) cborKey(key int64, path string) string {
+	if name, ok := coswidKeys[key]; ok {
+		return name
+	}
+	switch {
+	case isPrivateUse(key):
+	case !inRegistryRange(key):
+		v.add(path, "key %d out of range", key)
+	default:
+		v.add(path, "unregistered key %d", key)
+	}
+	return fmt.Sprint(key)
+}
@@ -0,0 +1,384 @@
+package uswid
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SWIDNamespace is the XML namespace of ISO/IEC 19770-2:2015 SWID tags.
+const SWIDNamespace = "http://standards.iso.org/iso/19770/-2/2015/schema.xsd"
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// xsdType checks an attribute value and returns why it is invalid, or an
+// empty string.
+type xsdType func(value string) string
+
+// xsdElement describes the attributes and child elements of a SWID element
+// type as defined by the ISO/IEC 19770-2:2015 schema. Attributes and elements
+// of other namespaces are always allowed.
+type xsdElement struct {
+	attrs    map[string]xsdType
+	required []string
+	children map[string]*xsdElement
+}
+
+var (
+	languagePattern = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+	integerPattern  = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	// an attribute name in a start tag, without namespace prefix
+	attrPattern = regexp.MustCompile(`[\s:]([^\s:=/>]+)\s*=`)
+
+	// the roles of ISO/IEC 19770-2:2015 plus maintainer, which RFC 9393
+	// registered for both SWID and CoSWID
+	xmlRoles = map[string]bool{
+		"tagCreator":      true,
+		"softwareCreator": true,
+		"aggregator":      true,
+		"distributor":     true,
+		"licensor":        true,
+		"maintainer":      true,
+	}
+)
+
+func xsString(value string) string {
+	return ""
+}
+
+func xsInteger(value string) string {
+	if !integerPattern.MatchString(value) {
+		return fmt.Sprintf("%q is not an integer", value)
+	}
+	return ""
+}
+
+func xsBoolean(value string) string {
+	switch value {
+	case "true", "false", "1", "0":
+		return ""
+	}
+	return fmt.Sprintf("%q is not a boolean", value)
+}
+
+func xsAnyURI(value string) string {
+	if _, err := url.Parse(value); err != nil {
+		return fmt.Sprintf("%q is not a URI", value)
+	}
+	return ""
+}
+
+func xsNMTOKEN(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\r\n") {
+		return fmt.Sprintf("%q is not a single token", value)
+	}
+	return ""
+}
+
+func xsDateTime(value string) string {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if _, err := time.Parse(layout, value); err == nil {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%q is not a date and time", value)
+}
+
+func xsLanguage(value string) string {
+	if !languagePattern.MatchString(value) {
+		return fmt.Sprintf("%q is not a language tag", value)
+	}
+	return ""
+}
+
+func enum(values ...string) xsdType {
+	return func(value string) string {
+		for _, v := range values {
+			if value == v {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%q is not one of %s", value, strings.Join(values, ", "))
+	}
+}
+
+func roles(value string) string {
+	list := strings.Fields(value)
+	if len(list) == 0 {
+		return "no role"
+	}
+	for _, role := range list {
+		if !xmlRoles[role] {
+			return fmt.Sprintf("unknown role %q", role)
+		}
+	}
+	return ""
+}
+
+// swidSchema returns the schema of the SoftwareIdentity element.
+func swidSchema() *xsdElement {
+	meta := &xsdElement{}
+	file := &xsdElement{
+		attrs: map[string]xsdType{
+			"key":      xsBoolean,
+			"location": xsString,
+			"name":     xsString,
+			"root":     xsString,
+			"size":     xsInteger,
+			"version":  xsString,
+		},
+		required: []string{"name"},
+	}
+	directory := &xsdElement{
+		attrs: map[string]xsdType{
+			"key":      xsBoolean,
+			"location": xsString,
+			"name":     xsString,
+			"root":     xsString,
+		},
+		required: []string{"name"},
+	}
+	directory.children = map[string]*xsdElement{"Directory": directory, "File": file}
+	resources := map[string]*xsdElement{
+		"Directory": directory,
+		"File":      file,
+		"Process": {
+			attrs:    map[string]xsdType{"name": xsString, "pid": xsInteger},
+			required: []string{"name"},
+		},
+		"Resource": {
+			attrs:    map[string]xsdType{"type": xsString},
+			required: []string{"type"},
+		},
+	}
+	return &xsdElement{
+		attrs: map[string]xsdType{
+			"corpus":        xsBoolean,
+			"media":         xsString,
+			"name":          xsString,
+			"patch":         xsBoolean,
+			"supplemental":  xsBoolean,
+			"tagId":         xsString,
+			"tagVersion":    xsInteger,
+			"version":       xsString,
+			"versionScheme": xsNMTOKEN,
+		},
+		required: []string{"name", "tagId"},
+		children: map[string]*xsdElement{
+			"Entity": {
+				attrs: map[string]xsdType{
+					"name":       xsString,
+					"regid":      xsAnyURI,
+					"role":       roles,
+					"thumbprint": xsString,
+				},
+				required: []string{"name", "role"},
+				children: map[string]*xsdElement{"Meta": meta},
+			},
+			"Evidence": {
+				attrs:    map[string]xsdType{"date": xsDateTime, "deviceId": xsString},
+				children: resources,
+			},
+			"Link": {
+				attrs: map[string]xsdType{
+					"artifact":  xsString,
+					"href":      xsAnyURI,
+					"media":     xsString,
+					"ownership": enum("abandon", "private", "shared"),
+					"rel":       xsNMTOKEN,
+					"type":      xsString,
+					"use":       enum("optional", "required", "recommended"),
+				},
+				required: []string{"href", "rel"},
+			},
+			"Meta":    meta,
+			"Payload": {children: resources},
+		},
+	}
+}
+
+// xmlValidator keeps track of the position in the document.
+type xmlValidator struct {
+	data       []byte
+	violations []Violation
+}
+
+func (v *xmlValidator) add(offset int64, path string, format string, args ...interface{}) {
+	line, column := position(v.data, offset)
+	v.violations = append(v.violations, Violation{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+		Line:    line,
+		Column:  column,
+	})
+}
+
+// position returns the line and column (both starting at 1) of offset in
+// data, columns are counted in characters.
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	head := data[:offset]
+	line := bytes.Count(head, []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(head, '\n') + 1
+	return line, utf8.RuneCount(head[lineStart:]) + 1
+}
+
+// attrOffset returns the offset of the attribute name in the start tag
+// data[start:end], or start if it can't be found.
+func attrOffset(data []byte, start, end int64, name string) int64 {
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	for _, loc := range attrPattern.FindAllSubmatchIndex(data[start:end], -1) {
+		if string(data[start+int64(loc[2]):start+int64(loc[3])]) == name {
+			return start + int64(loc[2])
+		}
+	}
+	return start
+}
+
+// ValidateXML checks SWID tags in XML format against the constraints of the
+// ISO/IEC 19770-2:2015 schema: the namespace of all elements, required
+// attributes, the types of attribute values, allowed roles and xml:lang.
+// data may hold several SoftwareIdentity elements, like the output of ToXML.
+// The violations carry the line and column they were found at, their paths
+// are XPath like, e.g. "/SoftwareIdentity[1]/Entity[2]/@role".
+func ValidateXML(data []byte) []Violation {
+	v := &xmlValidator{data: data}
+	schema := swidSchema()
+	d := xml.NewDecoder(bytes.NewReader(data))
+
+	type frame struct {
+		schema *xsdElement
+		path   string
+		counts map[string]int
+		// number of Entity elements with and without tagCreator role
+		entities    int
+		tagCreators int
+		start       int64
+	}
+	root := &frame{counts: map[string]int{}}
+	stack := []*frame{root}
+	skip := 0
+	roots := 0
+	for {
+		start := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line := 0
+			if serr, ok := err.(*xml.SyntaxError); ok {
+				line = serr.Line
+			}
+			v.violations = append(v.violations, Violation{Path: "/", Message: err.Error(), Line: line})
+			break
+		}
+		end := d.InputOffset()
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			parent := stack[len(stack)-1]
+			parent.counts[t.Name.Local]++
+			path := fmt.Sprintf("%s/%s[%d]", parent.path, t.Name.Local, parent.counts[t.Name.Local])
+			if t.Name.Space != SWIDNamespace {
+				if parent == root {
+					if t.Name.Space == "" {
+						v.add(start, path, "element %s is not in the namespace %s", t.Name.Local, SWIDNamespace)
+					} else {
+						v.add(start, path, "namespace %s is not the ISO/IEC 19770-2:2015 namespace %s", t.Name.Space, SWIDNamespace)
+					}
+				} else if t.Name.Space == "" {
+					v.add(start, path, "element %s is not in the namespace %s", t.Name.Local, SWIDNamespace)
+				}
+				// elements of other namespaces are extensions
+				skip = 1
+				continue
+			}
+
+			var s *xsdElement
+			if parent == root {
+				roots++
+				if t.Name.Local == "SoftwareIdentity" {
+					s = schema
+				}
+			} else {
+				s = parent.schema.children[t.Name.Local]
+			}
+			if s == nil {
+				v.add(start, path, "unexpected element %s", t.Name.Local)
+				skip = 1
+				continue
+			}
+
+			present := map[string]bool{}
+			for _, attr := range t.Attr {
+				apath := path + "/@" + attr.Name.Local
+				switch attr.Name.Space {
+				case "":
+					present[attr.Name.Local] = true
+					if check, ok := s.attrs[attr.Name.Local]; ok {
+						if msg := check(attr.Value); msg != "" {
+							v.add(attrOffset(v.data, start, end, attr.Name.Local), apath, "%s", msg)
+						}
+					}
+				case xmlNamespace:
+					if attr.Name.Local == "lang" {
+						if msg := xsLanguage(attr.Value); msg != "" {
+							v.add(attrOffset(v.data, start, end, "lang"), path+"/@xml:lang", "%s", msg)
+						}
+					}
+				}
+			}
+			for _, name := range s.required {
+				if !present[name] {
+					v.add(start, path, "missing attribute %s", name)
+				}
+			}
+			if t.Name.Local == "Entity" {
+				parent.entities++
+				for _, attr := range t.Attr {
+					if attr.Name.Space == "" && attr.Name.Local == "role" {
+						for _, role := range strings.Fields(attr.Value) {
+							if role == "tagCreator" {
+								parent.tagCreators++
+								break
+							}
+						}
+					}
+				}
+			}
+			stack = append(stack, &frame{schema: s, path: path, counts: map[string]int{}, start: start})
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if f.schema == schema {
+				if f.entities == 0 {
+					v.add(f.start, f.path, "missing Entity element")
+				} else if f.tagCreators == 0 {
+					v.add(f.start, f.path, "no Entity with role tagCreator")
+				}
+			}
+		}
+	}
+	if roots == 0 && len(v.violations) == 0 {
+		v.violations = append(v.violations, Violation{Path: "/", Message: "no SoftwareIdentity element"})
+	}
+	return v.violations
+}
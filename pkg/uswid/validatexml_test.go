@@ -0,0 +1,135 @@
+package uswid
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const xmlHead = `<?xml version="1.0" encoding="UTF-8"?>
+<SoftwareIdentity xmlns="http://standards.iso.org/iso/19770/-2/2015/schema.xsd"`
+
+func TestValidateXML(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+		want []Violation
+	}{
+		{
+			name: "valid",
+			xml: xmlHead + ` tagId="foo" name="foo">
+  <Entity name="ACME" role="tagCreator softwareCreator"/>
+  <Link href="swid:bar" rel="requires"/>
+</SoftwareIdentity>`,
+		},
+		{
+			name: "missing name and tagId",
+			xml: xmlHead + `>
+  <Entity name="ACME" role="tagCreator"/>
+</SoftwareIdentity>`,
+			want: []Violation{
+				{Path: "/SoftwareIdentity[1]", Message: "missing attribute name", Line: 2, Column: 1},
+				{Path: "/SoftwareIdentity[1]", Message: "missing attribute tagId", Line: 2, Column: 1},
+			},
+		},
+		{
+			name: "unknown role",
+			xml: xmlHead + ` tagId="foo" name="foo">
+  <Entity name="ACME"   role="boss tagCreator"/>
+</SoftwareIdentity>`,
+			want: []Violation{
+				{Path: "/SoftwareIdentity[1]/Entity[1]/@role", Message: `unknown role "boss"`, Line: 3, Column: 25},
+			},
+		},
+		{
+			name: "wrong namespace",
+			xml: `<SoftwareIdentity xmlns="http://example.com/swid" tagId="foo" name="foo">
+  <Entity name="ACME" role="tagCreator"/>
+</SoftwareIdentity>`,
+			want: []Violation{
+				{Path: "/SoftwareIdentity[1]", Message: "namespace http://example.com/swid is not the ISO/IEC 19770-2:2015 namespace " + SWIDNamespace, Line: 1, Column: 1},
+			},
+		},
+		{
+			name: "empty namespace",
+			xml: `<SoftwareIdentity tagId="foo" name="foo">
+  <Entity name="ACME" role="tagCreator"/>
+</SoftwareIdentity>`,
+			want: []Violation{
+				{Path: "/SoftwareIdentity[1]", Message: "element SoftwareIdentity is not in the namespace " + SWIDNamespace, Line: 1, Column: 1},
+			},
+		},
+		{
+			name: "bad xml:lang",
+			xml: xmlHead + ` tagId="foo" name="foo"
+  xml:lang="!!">
+  <Entity name="ACME" role="tagCreator"/>
+</SoftwareIdentity>`,
+			want: []Violation{
+				{Path: "/SoftwareIdentity[1]/@xml:lang", Message: `"!!" is not a language tag`, Line: 3, Column: 7},
+			},
+		},
+		{
+			name: "rel is no NMTOKEN",
+			xml: xmlHead + ` tagId="foo" name="foo">
+  <Entity name="ACME" role="tagCreator"/>
+  <Link href="https://example.com" rel="see also"/>
+</SoftwareIdentity>`,
+			want: []Violation{
+				{Path: "/SoftwareIdentity[1]/Link[1]/@rel", Message: `"see also" is not a single token`, Line: 4, Column: 36},
+			},
+		},
+		{
+			name: "missing tagCreator",
+			xml: xmlHead + ` tagId="foo" name="foo">
+  <Entity name="ACME" role="softwareCreator"/>
+</SoftwareIdentity>`,
+			want: []Violation{
+				{Path: "/SoftwareIdentity[1]", Message: "no Entity with role tagCreator", Line: 2, Column: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateXML([]byte(tt.xml)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateXML =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateXMLRoundTrip(t *testing.T) {
+	var u UswidSoftwareIdentity
+	// rels given as code points are spelled with spaces by the swid package
+	err := u.FromJSON(`{"tag-id":"foo","software-name":"foo","software-version":"1.0","entity":[{"entity-name":"ACME","role":["tagCreator","softwareCreator"]}],` +
+		`"link":[{"href":"https://example.com","rel":11},{"href":"https://example.com/media","rel":6},{"href":"https://example.com/installer","rel":7},{"href":"swid:bar","rel":"requires"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := u.ToXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if violations := ValidateXML(out); len(violations) != 0 {
+		t.Errorf("ValidateXML of ToXML output = %q\n%s", violations, out)
+	}
+
+	var back UswidSoftwareIdentity
+	if err := back.FromXML(string(out)); err != nil {
+		t.Fatal(err)
+	}
+	var rels []string
+	for _, l := range links(back.Identities[0]) {
+		rels = append(rels, l.Rel.String())
+	}
+	var want []string
+	for _, l := range links(u.Identities[0]) {
+		want = append(want, l.Rel.String())
+	}
+	if !reflect.DeepEqual(rels, want) {
+		t.Errorf("rels after round trip = %q, want %q", rels, want)
+	}
+	if strings.Contains(string(out), `rel="see also"`) {
+		t.Errorf("ToXML wrote a spaced rel:\n%s", out)
+	}
+}